@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
+	"github.com/spf13/cobra"
+)
+
+var idleTimeoutFlag time.Duration
+
+func agentCommandRunWithError(cmd *cobra.Command, args []string) error {
+	if idleTimeoutFlag <= 0 {
+		return fmt.Errorf("--idle_timeout must be positive, got %v", idleTimeoutFlag)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return transport.ServeAgent(ctx, idleTimeoutFlag)
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep warm connections to the targets for the following commands.",
+	Long: `Keep warm connections to the targets for the following commands.
+
+The agent listens on a Unix socket only accessible by the current user. While
+it runs, the other commands send their admin RPCs through it, instead of
+connecting to the targets every time. Connections unused for --idle_timeout are
+closed.`,
+	Args: cobra.NoArgs,
+	RunE: agentCommandRunWithError,
+}
+
+func init() {
+	agentCmd.Flags().DurationVar(&idleTimeoutFlag, "idle_timeout", 10*time.Minute, "Closes the connections unused for this long")
+	rootCmd.AddCommand(agentCmd)
+}
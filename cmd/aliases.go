@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// aliasGroup lists the aliases of the config in the help, apart from the
+// built-in commands
+const aliasGroup = "aliases"
+
+// aliasPlaceholderPattern matches the placeholders of the arguments in the
+// command line of an alias, e.g. $1 or $@
+var aliasPlaceholderPattern = regexp.MustCompile(`\$(\d+|@)`)
+
+// registeredAliases maps the names of the aliases added as commands to
+// their command lines
+var registeredAliases = make(map[string]*config.Alias)
+
+// expandAlias replaces the placeholders of the command line with the
+// arguments. The arguments are appended if there is no placeholder.
+func expandAlias(alias *config.Alias, args []string) ([]string, error) {
+	var expanded []string
+	placeholders := false
+	for _, word := range alias.Args() {
+		if word == "$@" {
+			expanded = append(expanded, args...)
+			placeholders = true
+			continue
+		}
+		var err error
+		word = aliasPlaceholderPattern.ReplaceAllStringFunc(word, func(placeholder string) string {
+			placeholders = true
+			n, _ := strconv.Atoi(placeholder[1:])
+			if n < 1 || n > len(args) {
+				err = fmt.Errorf("alias %q expects at least %v argument(s), got %v", alias.Name, n, len(args))
+				return ""
+			}
+			return args[n-1]
+		})
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, word)
+	}
+	if !placeholders {
+		expanded = append(expanded, args...)
+	}
+	return expanded, nil
+}
+
+// lookupAliasFlag finds the flag of the command run by an alias, by its name
+// or its shorthand
+func lookupAliasFlag(cmd *cobra.Command, name string, shorthand bool) *pflag.Flag {
+	for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.InheritedFlags()} {
+		var flag *pflag.Flag
+		if shorthand {
+			flag = flags.ShorthandLookup(name)
+		} else {
+			flag = flags.Lookup(name)
+		}
+		if flag != nil {
+			return flag
+		}
+	}
+	return nil
+}
+
+// splitAliasArgs separates the arguments of an alias from the flags, which
+// are passed through to the command line. The flags are those of cmd, the
+// command run by the alias.
+func splitAliasArgs(cmd *cobra.Command, args []string) ([]string, []string) {
+	var positional, flags []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(positional, args[i+1:]...), flags
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		// The value of a flag is its next argument, unless it's a boolean
+		name := strings.TrimLeft(arg, "-")
+		flag := lookupAliasFlag(cmd, name, len(name) == 1 && !strings.HasPrefix(arg, "--"))
+		if flag != nil && flag.NoOptDefVal == "" && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return positional, flags
+}
+
+// newAliasCommand returns the command listing the alias in the help. Its
+// command line is run by expandAliasArgs, so only its help is left to run.
+func newAliasCommand(alias *config.Alias) *cobra.Command {
+	short := alias.Description
+	if short == "" {
+		short = fmt.Sprintf("Alias of %q.", alias.Command)
+	}
+	return &cobra.Command{
+		Use:     alias.Name + " [args]",
+		Short:   short,
+		Long:    fmt.Sprintf("%v\n\nRuns \"grpcdebug %v\", defined at %v.", short, alias.Command, alias.Origin),
+		GroupID: aliasGroup,
+		// The flags are parsed by the command of the alias
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+}
+
+// expandAliasArgs rewrites the arguments running an alias into the command
+// line of the alias, before they are executed, e.g. "grpcdebug prod cds"
+// into "grpcdebug xds config --type=CDS --target prod". The arguments asking
+// for the help of the alias are left as is.
+func expandAliasArgs(args []string) ([]string, error) {
+	cmd, rest, err := rootCmd.Find(args)
+	if err != nil || cmd.GroupID != aliasGroup {
+		return args, nil
+	}
+	alias := registeredAliases[cmd.Name()]
+	target, _, err := rootCmd.Find(legacyTargetArgs(alias.Args()))
+	if err != nil {
+		target = rootCmd
+	}
+	positional, flags := splitAliasArgs(target, rest)
+	for _, arg := range flags {
+		if arg == "-h" || arg == "--help" {
+			return args, nil
+		}
+	}
+	expanded, err := expandAlias(alias, positional)
+	if err != nil {
+		return nil, err
+	}
+	expanded = legacyTargetArgs(append(expanded, flags...))
+	if cmd, _, err := rootCmd.Find(expanded); err == nil && cmd.GroupID == aliasGroup {
+		return nil, fmt.Errorf("alias %q can't be run by another alias", alias.Name)
+	}
+	return expanded, nil
+}
+
+// registerAliases adds the aliases of the config as commands. Those which
+// have the name of a built-in command, or of a target, are ignored, and
+// reported by "config validate".
+func registerAliases() {
+	// The config errors are reported by the commands loading it
+	aliases, _ := config.Aliases()
+	var commands []*cobra.Command
+	for _, alias := range aliases {
+		if cmd, _, err := rootCmd.Find([]string{alias.Name}); err == nil && cmd != rootCmd {
+			continue
+		}
+		if isTargetName(alias.Name) {
+			continue
+		}
+		registeredAliases[alias.Name] = alias
+		commands = append(commands, newAliasCommand(alias))
+	}
+	if len(commands) == 0 {
+		return
+	}
+	if !rootCmd.ContainsGroup(aliasGroup) {
+		rootCmd.AddGroup(&cobra.Group{ID: aliasGroup, Title: "Aliases from the config:"})
+	}
+	rootCmd.AddCommand(commands...)
+}
+
+// isTargetName tells whether the name is an address or a URI, which would be
+// taken as the target given before the command, e.g. "grpcdebug
+// localhost:50051 health"
+func isTargetName(name string) bool {
+	return strings.ContainsAny(name, ":/")
+}
+
+// checkAlias verifies the alias doesn't shadow a built-in command nor a
+// target, and runs a built-in command
+func checkAlias(name string, args []string) error {
+	if cmd, _, err := rootCmd.Find([]string{name}); err == nil && cmd != rootCmd && cmd.GroupID != aliasGroup {
+		return fmt.Errorf("it has the name of the command %q", cmd.CommandPath())
+	}
+	if isTargetName(name) {
+		return fmt.Errorf("%q would be taken as the target given before a command", name)
+	}
+	cmd, _, err := rootCmd.Find(legacyTargetArgs(args))
+	if err != nil || cmd == rootCmd {
+		return fmt.Errorf("%q doesn't run any command", strings.Join(args, " "))
+	}
+	if cmd.GroupID == aliasGroup {
+		return fmt.Errorf("it runs the alias %q, aliases can't run each other", cmd.Name())
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const aliasesConfig = `servers:
+  prod:
+    real_address: localhost:50051
+aliases:
+  check: health -o csv
+  check-of: health $2 --target $1 -o csv
+  check-all: health $@ -o csv
+  nested: check
+  "localhost:50051": channelz channels
+`
+
+// unregisterAliases removes the commands added by registerAliases
+func unregisterAliases() {
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.GroupID == aliasGroup {
+			rootCmd.RemoveCommand(cmd)
+		}
+	}
+	for name := range registeredAliases {
+		delete(registeredAliases, name)
+	}
+}
+
+func TestExpandAliasArgs(t *testing.T) {
+	writeConfig(t, aliasesConfig)
+	registerAliases()
+	defer unregisterAliases()
+	for _, test := range []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "appended arguments",
+			args: []string{"prod", "check", "foo"},
+			want: []string{"health", "-o", "csv", "foo", "--target", "prod"},
+		},
+		{
+			name: "numbered placeholders",
+			args: []string{"check-of", "prod", "foo", "--verbose"},
+			want: []string{"health", "foo", "--target", "prod", "-o", "csv", "--verbose"},
+		},
+		{
+			name: "all arguments",
+			args: []string{"check-all", "foo", "bar", "--target", "prod"},
+			want: []string{"health", "foo", "bar", "-o", "csv", "--target", "prod"},
+		},
+		{
+			name: "help of the alias",
+			args: []string{"check-of", "--help"},
+			want: []string{"check-of", "--help"},
+		},
+		{
+			name: "not an alias",
+			args: []string{"health", "foo"},
+			want: []string{"health", "foo"},
+		},
+		{
+			name:    "missing argument",
+			args:    []string{"check-of", "prod"},
+			wantErr: `alias "check-of" expects at least 2 argument(s), got 1`,
+		},
+		{
+			name:    "alias running an alias",
+			args:    []string{"nested"},
+			wantErr: `alias "nested" can't be run by another alias`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := expandAliasArgs(legacyTargetArgs(test.args))
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("expandAliasArgs(%q) = %q, %v, want error %q", test.args, got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expandAliasArgs(%q) = %q, %v, want %q", test.args, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestAliasCommand(t *testing.T) {
+	backends := map[string]*fakeBackend{
+		"localhost:50051": {health: map[string]string{"": "SERVING", "foo": "NOT_SERVING"}},
+	}
+	result := runCommandWithConfig(t, aliasesConfig, backends, "check-of", "prod", "foo")
+	if result.err != nil {
+		t.Fatalf("grpcdebug check-of failed: %v", result.err)
+	}
+	if want := "<Overall>,SERVING\nfoo,NOT_SERVING\n"; result.stdout != want {
+		t.Errorf("grpcdebug check-of printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+}
+
+func TestAliasShadowingTarget(t *testing.T) {
+	backends := map[string]*fakeBackend{
+		"localhost:50051": {health: map[string]string{"": "SERVING"}},
+	}
+	// The alias named like the target is ignored
+	result := runCommandWithConfig(t, aliasesConfig, backends, "localhost:50051", "health", "-o", "csv")
+	if result.err != nil {
+		t.Fatalf("grpcdebug localhost:50051 health failed: %v", result.err)
+	}
+	if want := "<Overall>,SERVING\n"; result.stdout != want {
+		t.Errorf("grpcdebug localhost:50051 health printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+
+	for _, name := range []string{"localhost:50051", "xds:///my-service", "unix:///tmp/grpc.sock"} {
+		if err := checkAlias(name, []string{"health"}); err == nil || !strings.Contains(err.Error(), "would be taken as the target") {
+			t.Errorf("checkAlias(%q) = %v, want error of the target", name, err)
+		}
+	}
+	if err := checkAlias("check", []string{"health"}); err != nil {
+		t.Errorf("checkAlias(%q) = %v, want no error", "check", err)
+	}
+}
@@ -1,18 +1,19 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/dustin/go-humanize"
 	"github.com/golang/protobuf/ptypes"
 	timestamppb "github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
 	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
 	"github.com/spf13/cobra"
 	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/protobuf/encoding/prototext"
 )
 
 var (
@@ -37,11 +38,40 @@ func prettyAddress(addr *zpb.Address) string {
 		address := net.TCPAddr{IP: net.IP(ipPort.IpAddress), Port: int(ipPort.Port)}
 		return address.String()
 	}
-	panic(fmt.Sprintf("Address type not supported for %s", addr))
+	if uds := addr.GetUdsAddress(); uds != nil {
+		filename := uds.GetFilename()
+		if filename == "" || filename == "@" {
+			return "unix:<unnamed>"
+		}
+		// Abstract sockets are reported with a leading "@" or NUL byte
+		if strings.HasPrefix(filename, "@") || strings.HasPrefix(filename, "\x00") {
+			return "unix-abstract:" + filename[1:]
+		}
+		return "unix:" + filename
+	}
+	if other := addr.GetOtherAddress(); other != nil {
+		return prettyOtherAddress(other)
+	}
+	verbose.Debugf("address type not supported for %s", addr)
+	return "<unknown>"
+}
+
+// prettyOtherAddress prints the name of the address, followed by its payload
+// if the payload type is known
+func prettyOtherAddress(other *zpb.Address_OtherAddress) string {
+	if other.GetValue() == nil {
+		return other.GetName()
+	}
+	payload, err := other.GetValue().UnmarshalNew()
+	if err != nil {
+		verbose.Debugf("failed to unpack address payload: %v", err)
+		return fmt.Sprintf("%v(%v)", other.GetName(), other.GetValue().GetTypeUrl())
+	}
+	return fmt.Sprintf("%v(%v)", other.GetName(), prototext.MarshalOptions{}.Format(payload))
 }
 
-func printChannelTraceEvents(events []*zpb.ChannelTraceEvent) {
-	fmt.Fprintln(w, "Severity\tTime\tChild Ref\tDescription\t")
+func printChannelTraceEvents(t *target, events []*zpb.ChannelTraceEvent) {
+	table := t.newTable("", "Severity", "Time", "Child Ref", "Description")
 	for _, event := range events {
 		var childRef string
 		switch event.ChildRef.(type) {
@@ -50,76 +80,81 @@ func printChannelTraceEvents(events []*zpb.ChannelTraceEvent) {
 		case *zpb.ChannelTraceEvent_ChannelRef:
 			childRef = fmt.Sprintf("channel(%v)", event.GetChannelRef())
 		}
-		fmt.Fprintf(
-			w, "%v\t%v\t%v\t%v\t\n",
+		table.addRow(
 			event.Severity,
 			prettyTime(event.Timestamp),
 			childRef,
 			event.Description,
 		)
 	}
-	w.Flush()
 }
 
-func printSockets(sockets []*zpb.Socket) {
-	fmt.Fprintln(w, "Socket ID\tLocal->Remote\tStreams(Started/Succeeded/Failed)\tMessages(Sent/Received)\t")
+func printSockets(t *target, sockets []*zpb.Socket) {
+	table := t.newTable("", "Socket ID", "Local->Remote", "Streams(Started/Succeeded/Failed)", "Messages(Sent/Received)")
 	for _, socket := range sockets {
 		if socket.GetRef() == nil || socket.GetData() == nil {
 			verbose.Debugf("failed to print socket: %s", socket)
 			continue
 		}
-		fmt.Fprintf(
-			w, "%v\t%v\t%v/%v/%v\t%v/%v\t\n",
+		table.addRow(
 			socket.Ref.SocketId,
 			fmt.Sprintf("%v->%v", prettyAddress(socket.Local), prettyAddress(socket.Remote)),
-			socket.Data.StreamsStarted,
-			socket.Data.StreamsSucceeded,
-			socket.Data.StreamsFailed,
-			socket.Data.MessagesSent,
-			socket.Data.MessagesReceived,
+			fmt.Sprintf("%v/%v/%v", socket.Data.StreamsStarted, socket.Data.StreamsSucceeded, socket.Data.StreamsFailed),
+			fmt.Sprintf("%v/%v", socket.Data.MessagesSent, socket.Data.MessagesReceived),
 		)
 	}
-	w.Flush()
 }
 
-func printObjectAsJSON(data interface{}) error {
-	json, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return err
+// fetchSockets fetches the referenced sockets, the failures are recorded in
+// the report of the target
+func fetchSockets(ctx context.Context, t *target, socketRefs []*zpb.SocketRef) []*zpb.Socket {
+	var sockets []*zpb.Socket
+	for _, socketRef := range socketRefs {
+		socket, err := t.backend.Socket(ctx, socketRef.GetSocketId())
+		if err != nil {
+			t.fail(err)
+			continue
+		}
+		sockets = append(sockets, socket)
 	}
-	fmt.Println(string(json))
-	return nil
+	return sockets
+}
+
+// fetchListenAddresses returns the addresses of the server's listen sockets,
+// the failures are recorded in the report of the target
+func fetchListenAddresses(ctx context.Context, t *target, server *zpb.Server) []string {
+	var listenAddresses []string
+	for _, socket := range fetchSockets(ctx, t, server.GetListenSocket()) {
+		listenAddresses = append(listenAddresses, prettyAddress(socket.GetLocal()))
+	}
+	return listenAddresses
 }
 
 func printCreationTimestamp(data *zpb.ChannelData) string {
 	return prettyTime(data.GetTrace().GetCreationTimestamp())
 }
 
-func channelzChannelsCommandRunWithError(cmd *cobra.Command, args []string) error {
-	var channels = transport.Channels(startIDFlag, maxResultsFlag)
-	// Print as JSON
-	if jsonOutputFlag {
-		return printObjectAsJSON(channels)
+func channelzChannelsCommandRunWithError(ctx context.Context, t *target, args []string) error {
+	channels, err := t.backend.Channels(ctx, startIDFlag, maxResultsFlag)
+	if err != nil {
+		return err
 	}
-	// Print as table
-	fmt.Fprintln(w, "Channel ID\tTarget\tState\tCalls(Started/Succeeded/Failed)\tCreated Time\t")
+	t.setData(channels)
+	table := t.newTable("", "Channel ID", "Target", "State", "Calls(Started/Succeeded/Failed)", "Created Time").addWideColumn("Last Call Started")
 	for _, channel := range channels {
 		if channel.GetRef() == nil || channel.GetData() == nil {
 			verbose.Debugf("failed to print channel: %s", channel)
 			continue
 		}
-		fmt.Fprintf(
-			w, "%v\t%v\t%v\t%v/%v/%v\t%v\t\n",
+		table.addRow(
 			channel.Ref.ChannelId,
 			channel.Data.Target,
 			channel.Data.GetState().GetState(),
-			channel.Data.CallsStarted,
-			channel.Data.CallsSucceeded,
-			channel.Data.CallsFailed,
+			fmt.Sprintf("%v/%v/%v", channel.Data.CallsStarted, channel.Data.CallsSucceeded, channel.Data.CallsFailed),
 			printCreationTimestamp(channel.Data),
+			prettyTime(channel.Data.LastCallStartedTimestamp),
 		)
 	}
-	w.Flush()
 	return nil
 }
 
@@ -127,56 +162,54 @@ var channelzChannelsCmd = &cobra.Command{
 	Use:   "channels",
 	Short: "List client channels for the target application.",
 	Args:  cobra.NoArgs,
-	RunE:  channelzChannelsCommandRunWithError,
+	RunE:  forEachTarget(channelzChannelsCommandRunWithError),
 }
 
-func channelzChannelCommandRunWithError(cmd *cobra.Command, args []string) error {
+func channelzChannelCommandRunWithError(ctx context.Context, t *target, args []string) error {
 	id, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
 		return fmt.Errorf("Failed to parse ID=%v: %v", args[0], err)
 	}
-	selected := transport.Channel(id)
-	// Print as JSON
-	if jsonOutputFlag {
-		return printObjectAsJSON(selected)
+	selected, err := t.backend.Channel(ctx, id)
+	if err != nil {
+		return err
 	}
-	// Print as table
+	t.setData(selected)
 	// Print Channel information
-	fmt.Fprintf(w, "Channel ID:\t%v\t\n", selected.GetRef().GetChannelId())
-	fmt.Fprintf(w, "Target:\t%v\t\n", selected.GetData().GetTarget())
-	fmt.Fprintf(w, "State:\t%v\t\n", selected.GetData().GetState().GetState())
-	fmt.Fprintf(w, "Calls Started:\t%v\t\n", selected.GetData().GetCallsStarted())
-	fmt.Fprintf(w, "Calls Succeeded:\t%v\t\n", selected.GetData().GetCallsSucceeded())
-	fmt.Fprintf(w, "Calls Failed:\t%v\t\n", selected.GetData().GetCallsFailed())
-	fmt.Fprintf(w, "Created Time:\t%v\t\n", printCreationTimestamp(selected.GetData()))
-	w.Flush()
+	table := t.newTable("")
+	table.addRow("Channel ID:", selected.GetRef().GetChannelId())
+	table.addRow("Target:", selected.GetData().GetTarget())
+	table.addRow("State:", selected.GetData().GetState().GetState())
+	table.addRow("Calls Started:", selected.GetData().GetCallsStarted())
+	table.addRow("Calls Succeeded:", selected.GetData().GetCallsSucceeded())
+	table.addRow("Calls Failed:", selected.GetData().GetCallsFailed())
+	table.addRow("Created Time:", printCreationTimestamp(selected.GetData()))
 	// Print Subchannel list
 	if len(selected.GetSubchannelRef()) > 0 {
-		fmt.Println("---")
-		fmt.Fprintln(w, "Subchannel ID\tTarget\tState\tCalls(Started/Succeeded/Failed)\tCreatedTime\t")
+		table := t.newTable("", "Subchannel ID", "Target", "State", "Calls(Started/Succeeded/Failed)", "CreatedTime").addWideColumn("Last Call Started")
 		for _, subchannelRef := range selected.GetSubchannelRef() {
-			var subchannel = transport.Subchannel(subchannelRef.GetSubchannelId())
+			subchannel, err := t.backend.Subchannel(ctx, subchannelRef.GetSubchannelId())
+			if err != nil {
+				t.fail(err)
+				continue
+			}
 			if subchannel.GetRef() == nil || subchannel.GetData() == nil {
 				verbose.Debugf("failed to print subchannel: %s", subchannel)
 				continue
 			}
-			fmt.Fprintf(
-				w, "%v\t%.50s\t%v\t%v/%v/%v\t%v\t\n",
+			table.addRow(
 				subchannel.Ref.SubchannelId,
-				subchannel.Data.Target,
+				fmt.Sprintf("%.50s", subchannel.Data.Target),
 				subchannel.Data.State.State,
-				subchannel.Data.CallsStarted,
-				subchannel.Data.CallsSucceeded,
-				subchannel.Data.CallsFailed,
+				fmt.Sprintf("%v/%v/%v", subchannel.Data.CallsStarted, subchannel.Data.CallsSucceeded, subchannel.Data.CallsFailed),
 				printCreationTimestamp(subchannel.Data),
+				prettyTime(subchannel.Data.LastCallStartedTimestamp),
 			)
 		}
-		w.Flush()
 	}
 	// Print channel trace events
 	if len(selected.GetData().GetTrace().GetEvents()) != 0 {
-		fmt.Println("---")
-		printChannelTraceEvents(selected.Data.Trace.Events)
+		printChannelTraceEvents(t, selected.Data.Trace.Events)
 	}
 	return nil
 }
@@ -185,37 +218,31 @@ var channelzChannelCmd = &cobra.Command{
 	Use:   "channel <channel id or URL>",
 	Short: "Display channel states in a human readable way.",
 	Args:  cobra.ExactArgs(1),
-	RunE:  channelzChannelCommandRunWithError,
+	RunE:  forEachTarget(channelzChannelCommandRunWithError),
 }
 
-func channelzSubchannelCommandRunWithError(cmd *cobra.Command, args []string) error {
+func channelzSubchannelCommandRunWithError(ctx context.Context, t *target, args []string) error {
 	id, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
 		return fmt.Errorf("Failed to parse ID=%v: %v", args[0], err)
 	}
-	selected := transport.Subchannel(id)
-	// Print as JSON
-	if jsonOutputFlag {
-		return printObjectAsJSON(selected)
+	selected, err := t.backend.Subchannel(ctx, id)
+	if err != nil {
+		return err
 	}
-	// Print as table
+	t.setData(selected)
 	// Print Subchannel information
-	fmt.Fprintf(w, "Subchannel ID:\t%v\t\n", selected.GetRef().GetSubchannelId())
-	fmt.Fprintf(w, "Target:\t%v\t\n", selected.GetData().GetTarget())
-	fmt.Fprintf(w, "State:\t%v\t\n", selected.GetData().GetState().GetState())
-	fmt.Fprintf(w, "Calls Started:\t%v\t\n", selected.GetData().GetCallsStarted())
-	fmt.Fprintf(w, "Calls Succeeded:\t%v\t\n", selected.GetData().GetCallsSucceeded())
-	fmt.Fprintf(w, "Calls Failed:\t%v\t\n", selected.GetData().GetCallsFailed())
-	fmt.Fprintf(w, "Created Time:\t%v\t\n", printCreationTimestamp(selected.GetData()))
-	w.Flush()
+	table := t.newTable("")
+	table.addRow("Subchannel ID:", selected.GetRef().GetSubchannelId())
+	table.addRow("Target:", selected.GetData().GetTarget())
+	table.addRow("State:", selected.GetData().GetState().GetState())
+	table.addRow("Calls Started:", selected.GetData().GetCallsStarted())
+	table.addRow("Calls Succeeded:", selected.GetData().GetCallsSucceeded())
+	table.addRow("Calls Failed:", selected.GetData().GetCallsFailed())
+	table.addRow("Created Time:", printCreationTimestamp(selected.GetData()))
 	if len(selected.SocketRef) > 0 {
 		// Print socket list
-		fmt.Println("---")
-		var sockets []*zpb.Socket
-		for _, socketRef := range selected.GetSocketRef() {
-			sockets = append(sockets, transport.Socket(socketRef.GetSocketId()))
-		}
-		printSockets(sockets)
+		printSockets(t, fetchSockets(ctx, t, selected.GetSocketRef()))
 	}
 	return nil
 }
@@ -224,73 +251,69 @@ var channelzSubchannelCmd = &cobra.Command{
 	Use:   "subchannel <id>",
 	Short: "Display subchannel states in a human readable way.",
 	Args:  cobra.ExactArgs(1),
-	RunE:  channelzSubchannelCommandRunWithError,
+	RunE:  forEachTarget(channelzSubchannelCommandRunWithError),
 }
 
-func channelzSocketCommandRunWithError(cmd *cobra.Command, args []string) error {
+func channelzSocketCommandRunWithError(ctx context.Context, t *target, args []string) error {
 	socketID, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
 		return fmt.Errorf("Invalid socket ID %v", socketID)
 	}
-	selected := transport.Socket(socketID)
-	// Print as JSON
-	if jsonOutputFlag {
-		return printObjectAsJSON(selected)
+	selected, err := t.backend.Socket(ctx, socketID)
+	if err != nil {
+		return err
 	}
-	// Print as table
+	t.setData(selected)
 	// Print Socket information
-	fmt.Fprintf(w, "Socket ID:\t%v\t\n", selected.GetRef().GetSocketId())
-	fmt.Fprintf(w, "Address:\t%v\t\n", fmt.Sprintf("%v->%v", prettyAddress(selected.GetLocal()), prettyAddress(selected.GetRemote())))
-	fmt.Fprintf(w, "Streams Started:\t%v\t\n", selected.GetData().GetStreamsStarted())
-	fmt.Fprintf(w, "Streams Succeeded:\t%v\t\n", selected.GetData().GetStreamsSucceeded())
-	fmt.Fprintf(w, "Streams Failed:\t%v\t\n", selected.GetData().GetStreamsFailed())
-	fmt.Fprintf(w, "Messages Sent:\t%v\t\n", selected.GetData().GetMessagesSent())
-	fmt.Fprintf(w, "Messages Received:\t%v\t\n", selected.GetData().GetMessagesReceived())
-	fmt.Fprintf(w, "Keep Alives Sent:\t%v\t\n", selected.GetData().GetKeepAlivesSent())
-	fmt.Fprintf(w, "Last Local Stream Created:\t%v\t\n", prettyTime(selected.GetData().GetLastLocalStreamCreatedTimestamp()))
-	fmt.Fprintf(w, "Last Remote Stream Created:\t%v\t\n", prettyTime(selected.GetData().GetLastRemoteStreamCreatedTimestamp()))
-	fmt.Fprintf(w, "Last Message Sent Created:\t%v\t\n", prettyTime(selected.GetData().GetLastMessageSentTimestamp()))
-	fmt.Fprintf(w, "Last Message Received Created:\t%v\t\n", prettyTime(selected.GetData().GetLastMessageReceivedTimestamp()))
-	fmt.Fprintf(w, "Local Flow Control Window:\t%v\t\n", selected.GetData().GetLocalFlowControlWindow().GetValue())
-	fmt.Fprintf(w, "Remote Flow Control Window:\t%v\t\n", selected.GetData().GetRemoteFlowControlWindow().GetValue())
-	w.Flush()
+	table := t.newTable("")
+	table.addRow("Socket ID:", selected.GetRef().GetSocketId())
+	table.addRow("Address:", fmt.Sprintf("%v->%v", prettyAddress(selected.GetLocal()), prettyAddress(selected.GetRemote())))
+	table.addRow("Streams Started:", selected.GetData().GetStreamsStarted())
+	table.addRow("Streams Succeeded:", selected.GetData().GetStreamsSucceeded())
+	table.addRow("Streams Failed:", selected.GetData().GetStreamsFailed())
+	table.addRow("Messages Sent:", selected.GetData().GetMessagesSent())
+	table.addRow("Messages Received:", selected.GetData().GetMessagesReceived())
+	table.addRow("Keep Alives Sent:", selected.GetData().GetKeepAlivesSent())
+	table.addRow("Last Local Stream Created:", prettyTime(selected.GetData().GetLastLocalStreamCreatedTimestamp()))
+	table.addRow("Last Remote Stream Created:", prettyTime(selected.GetData().GetLastRemoteStreamCreatedTimestamp()))
+	table.addRow("Last Message Sent Created:", prettyTime(selected.GetData().GetLastMessageSentTimestamp()))
+	table.addRow("Last Message Received Created:", prettyTime(selected.GetData().GetLastMessageReceivedTimestamp()))
+	table.addRow("Local Flow Control Window:", selected.GetData().GetLocalFlowControlWindow().GetValue())
+	table.addRow("Remote Flow Control Window:", selected.GetData().GetRemoteFlowControlWindow().GetValue())
 	if len(selected.GetData().GetOption()) > 0 {
-		fmt.Println("---")
-		fmt.Fprintln(w, "Socket Options Name\tValue\t")
+		table := t.newTable("", "Socket Options Name", "Value")
 		for _, option := range selected.GetData().GetOption() {
 			if option.GetValue() != "" {
 				// Prefer human readable value than the Any proto
-				fmt.Fprintf(w, "%v\t%v\t\n", option.GetName(), option.GetValue())
+				table.addRow(option.GetName(), option.GetValue())
 			} else {
-				fmt.Fprintf(w, "%v\t%v\t\n", option.GetName(), option.GetAdditional())
+				table.addRow(option.GetName(), option.GetAdditional())
 			}
 		}
-		w.Flush()
 	}
 	// Print security information
 	if security := selected.GetSecurity(); security != nil {
-		fmt.Println("---")
+		table := t.newTable("")
 		switch x := security.Model.(type) {
 		case *zpb.Security_Tls_:
-			fmt.Fprintf(w, "Security Model:\t%v\t\n", "TLS")
+			table.addRow("Security Model:", "TLS")
 			switch y := security.GetTls().GetCipherSuite().(type) {
 			case *zpb.Security_Tls_StandardName:
-				fmt.Fprintf(w, "Standard Name:\t%v\t\n", security.GetTls().GetStandardName())
+				table.addRow("Standard Name:", security.GetTls().GetStandardName())
 			case *zpb.Security_Tls_OtherName:
-				fmt.Fprintf(w, "Other Name:\t%v\t\n", security.GetTls().GetOtherName())
+				table.addRow("Other Name:", security.GetTls().GetOtherName())
 			default:
 				return fmt.Errorf("Unexpected Cipher suite name type %T", y)
 			}
-			// fmt.Fprintf(w, "Local Certificate:\t%v\t\n", security.GetTls().LocalCertificate)
-			// fmt.Fprintf(w, "Remote Certificate:\t%v\t\n", security.GetTls().RemoteCertificate)
+			// table.addRow("Local Certificate:", security.GetTls().LocalCertificate)
+			// table.addRow("Remote Certificate:", security.GetTls().RemoteCertificate)
 		case *zpb.Security_Other:
-			fmt.Fprintf(w, "Security Model:\t%v\t\n", "Other")
-			fmt.Fprintf(w, "Name:\t%v\t\n", security.GetOther().GetName())
-			// fmt.Fprintf(w, "Value:\t%v\t\n", security.GetOther().Value)
+			table.addRow("Security Model:", "Other")
+			table.addRow("Name:", security.GetOther().GetName())
+			// table.addRow("Value:", security.GetOther().Value)
 		default:
 			return fmt.Errorf("Unexpected security model type %T", x)
 		}
-		w.Flush()
 	}
 	return nil
 }
@@ -299,34 +322,25 @@ var channelzSocketCmd = &cobra.Command{
 	Use:   "socket <id>",
 	Short: "Display socket states in a human readable way.",
 	Args:  cobra.ExactArgs(1),
-	RunE:  channelzSocketCommandRunWithError,
+	RunE:  forEachTarget(channelzSocketCommandRunWithError),
 }
 
-func channelzServersCommandRunWithError(cmd *cobra.Command, args []string) error {
-	var servers = transport.Servers(startIDFlag, maxResultsFlag)
-	// Print as JSON
-	if jsonOutputFlag {
-		return printObjectAsJSON(servers)
+func channelzServersCommandRunWithError(ctx context.Context, t *target, args []string) error {
+	servers, err := t.backend.Servers(ctx, startIDFlag, maxResultsFlag)
+	if err != nil {
+		return err
 	}
-	// Print as table
-	fmt.Fprintln(w, "Server ID\tListen Addresses\tCalls(Started/Succeeded/Failed)\tLast Call Started\t")
+	t.setData(servers)
+	table := t.newTable("", "Server ID", "Listen Addresses", "Calls(Started/Succeeded/Failed)", "Last Call Started")
 	for _, server := range servers {
-		var listenAddresses []string
-		for _, socketRef := range server.GetListenSocket() {
-			socket := transport.Socket(socketRef.SocketId)
-			listenAddresses = append(listenAddresses, prettyAddress(socket.GetLocal()))
-		}
-		fmt.Fprintf(
-			w, "%v\t%v\t%v/%v/%v\t%v\t\n",
+		listenAddresses := fetchListenAddresses(ctx, t, server)
+		table.addRow(
 			server.GetRef().GetServerId(),
 			listenAddresses,
-			server.GetData().GetCallsStarted(),
-			server.GetData().GetCallsSucceeded(),
-			server.GetData().GetCallsFailed(),
+			fmt.Sprintf("%v/%v/%v", server.GetData().GetCallsStarted(), server.GetData().GetCallsSucceeded(), server.GetData().GetCallsFailed()),
 			prettyTime(server.GetData().GetLastCallStartedTimestamp()),
 		)
 	}
-	w.Flush()
 	return nil
 }
 
@@ -334,36 +348,34 @@ var channelzServersCmd = &cobra.Command{
 	Use:   "servers",
 	Short: "List servers in a human readable way.",
 	Args:  cobra.NoArgs,
-	RunE:  channelzServersCommandRunWithError,
+	RunE:  forEachTarget(channelzServersCommandRunWithError),
 }
 
-func channelzServerCommandRunWithError(cmd *cobra.Command, args []string) error {
+func channelzServerCommandRunWithError(ctx context.Context, t *target, args []string) error {
 	serverID, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
 		return fmt.Errorf("Invalid server ID %v", serverID)
 	}
-	selected := transport.Server(serverID)
-	// Print as JSON
-	if jsonOutputFlag {
-		return printObjectAsJSON(selected)
+	selected, err := t.backend.Server(ctx, serverID)
+	if err != nil {
+		return err
 	}
-	// Print as table
-	var listenAddresses []string
-	for _, socketRef := range selected.GetListenSocket() {
-		socket := transport.Socket(socketRef.GetSocketId())
-		listenAddresses = append(listenAddresses, prettyAddress(socket.GetLocal()))
+	t.setData(selected)
+	listenAddresses := fetchListenAddresses(ctx, t, selected)
+	table := t.newTable("")
+	table.addRow("Server Id:", selected.GetRef().GetServerId())
+	table.addRow("Listen Addresses:", listenAddresses)
+	table.addRow("Calls Started:", selected.GetData().GetCallsStarted())
+	table.addRow("Calls Succeeded:", selected.GetData().GetCallsSucceeded())
+	table.addRow("Calls Failed:", selected.GetData().GetCallsFailed())
+	table.addRow("Last Call Started:", prettyTime(selected.GetData().GetLastCallStartedTimestamp()))
+	socketRefs, err := t.backend.ServerSockets(ctx, selected.GetRef().GetServerId(), startIDFlag, maxResultsFlag)
+	if err != nil {
+		t.fail(err)
 	}
-	fmt.Fprintf(w, "Server Id:\t%v\t\n", selected.GetRef().GetServerId())
-	fmt.Fprintf(w, "Listen Addresses:\t%v\t\n", listenAddresses)
-	fmt.Fprintf(w, "Calls Started:\t%v\t\n", selected.GetData().GetCallsStarted())
-	fmt.Fprintf(w, "Calls Succeeded:\t%v\t\n", selected.GetData().GetCallsSucceeded())
-	fmt.Fprintf(w, "Calls Failed:\t%v\t\n", selected.GetData().GetCallsFailed())
-	fmt.Fprintf(w, "Last Call Started:\t%v\t\n", prettyTime(selected.GetData().GetLastCallStartedTimestamp()))
-	w.Flush()
-	if sockets := transport.ServerSocket(selected.GetRef().GetServerId(), startIDFlag, maxResultsFlag); len(sockets) > 0 {
+	if len(socketRefs) > 0 {
 		// Print socket list
-		fmt.Println("---")
-		printSockets(sockets)
+		printSockets(t, fetchSockets(ctx, t, socketRefs))
 	}
 	return nil
 }
@@ -372,7 +384,7 @@ var channelzServerCmd = &cobra.Command{
 	Use:   "server <id>",
 	Short: "Display the server state in a human readable way.",
 	Args:  cobra.ExactArgs(1),
-	RunE:  channelzServerCommandRunWithError,
+	RunE:  forEachTarget(channelzServerCommandRunWithError),
 }
 
 var channelzCmd = &cobra.Command{
@@ -389,7 +401,8 @@ func init() {
 	channelzServerCmd.Flags().Int64VarP(&startIDFlag, "start_id", "s", 0, "The start server socket ID")
 	channelzServersCmd.Flags().Int64VarP(&maxResultsFlag, "max_results", "m", 100, "The maximum number of output servers")
 	channelzServersCmd.Flags().Int64VarP(&startIDFlag, "start_id", "s", 0, "The start server ID")
-	channelzCmd.PersistentFlags().BoolVarP(&jsonOutputFlag, "json", "o", false, "Whether to print the result as JSON")
+	channelzCmd.PersistentFlags().BoolVar(&jsonOutputFlag, "json", false, "Whether to print the result as JSON")
+	channelzCmd.PersistentFlags().MarkDeprecated("json", "use -o json instead, which follows the protobuf JSON mapping, e.g. camelCase keys and int64 as strings; -o is now the shorthand of --output rather than --json")
 	channelzCmd.AddCommand(channelzChannelCmd)
 	channelzCmd.AddCommand(channelzChannelsCmd)
 	channelzCmd.AddCommand(channelzSubchannelCmd)
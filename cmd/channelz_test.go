@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"testing"
+
+	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+)
+
+func fakeChannelzBackend() *fakeBackend {
+	return &fakeBackend{
+		channels: []*zpb.Channel{
+			{
+				Ref: &zpb.ChannelRef{ChannelId: 6},
+				Data: &zpb.ChannelData{
+					Target:         "backend.example.com:443",
+					State:          &zpb.ChannelConnectivityState{State: zpb.ChannelConnectivityState_READY},
+					CallsStarted:   3,
+					CallsSucceeded: 2,
+					CallsFailed:    1,
+				},
+				SubchannelRef: []*zpb.SubchannelRef{{SubchannelId: 7}},
+			},
+		},
+		servers: []*zpb.Server{
+			{
+				Ref:          &zpb.ServerRef{ServerId: 1},
+				Data:         &zpb.ServerData{CallsStarted: 10, CallsSucceeded: 9, CallsFailed: 1},
+				ListenSocket: []*zpb.SocketRef{{SocketId: 2}},
+			},
+		},
+		sockets: map[int64]*zpb.Socket{
+			2: {
+				Ref: &zpb.SocketRef{SocketId: 2},
+				Local: &zpb.Address{Address: &zpb.Address_TcpipAddress{
+					TcpipAddress: &zpb.Address_TcpIpAddress{IpAddress: []byte{127, 0, 0, 1}, Port: 50051},
+				}},
+			},
+		},
+	}
+}
+
+func TestChannelzChannels(t *testing.T) {
+	backends := map[string]*fakeBackend{"localhost:50051": fakeChannelzBackend()}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "table",
+			args: []string{"localhost:50051", "channelz", "channels"},
+			want: "Channel ID   Target                    State     Calls(Started/Succeeded/Failed)   Created Time   \n" +
+				"6            backend.example.com:443   READY     3/2/1                                            \n",
+		},
+		{
+			name: "json",
+			args: []string{"localhost:50051", "channelz", "channels", "-o", "json"},
+			want: `[
+  {
+    "ref": {
+      "channelId": "6"
+    },
+    "data": {
+      "state": {
+        "state": "READY"
+      },
+      "target": "backend.example.com:443",
+      "callsStarted": "3",
+      "callsSucceeded": "2",
+      "callsFailed": "1"
+    },
+    "subchannelRef": [
+      {
+        "subchannelId": "7"
+      }
+    ]
+  }
+]
+`,
+		},
+		{
+			name: "legacy json",
+			args: []string{"localhost:50051", "channelz", "channels", "--json"},
+			// Cobra prints the deprecation to its output, which is stdout
+			// once set
+			want: "Flag --json has been deprecated, use -o json instead, which follows the protobuf JSON mapping, e.g. camelCase keys and int64 as strings; -o is now the shorthand of --output rather than --json\n" + `[
+  {
+    "ref": {
+      "channel_id": 6
+    },
+    "data": {
+      "state": {
+        "state": 3
+      },
+      "target": "backend.example.com:443",
+      "calls_started": 3,
+      "calls_succeeded": 2,
+      "calls_failed": 1
+    },
+    "subchannel_ref": [
+      {
+        "subchannel_id": 7
+      }
+    ]
+  }
+]
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := runCommand(t, backends, test.args...)
+			if result.err != nil {
+				t.Fatalf("grpcdebug %v failed: %v\n%v", test.args, result.err, result.stderr)
+			}
+			if result.stdout != test.want {
+				t.Errorf("grpcdebug %v printed:\n%v\nwant:\n%v", test.args, result.stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestChannelzChannelsOfManyTargets(t *testing.T) {
+	backends := map[string]*fakeBackend{
+		"localhost:50051": fakeChannelzBackend(),
+		"localhost:50052": fakeChannelzBackend(),
+	}
+	result := runCommand(t, backends, "localhost:50051,localhost:50052", "channelz", "channels")
+	if result.err != nil {
+		t.Fatalf("grpcdebug channelz channels failed: %v\n%v", result.err, result.stderr)
+	}
+	// The tables are merged with a leading Target column
+	want := "Target            Channel ID   Target                    State     Calls(Started/Succeeded/Failed)   Created Time   \n" +
+		"localhost:50051   6            backend.example.com:443   READY     3/2/1                                            \n" +
+		"localhost:50052   6            backend.example.com:443   READY     3/2/1                                            \n"
+	if result.stdout != want {
+		t.Errorf("grpcdebug channelz channels printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+}
+
+func TestChannelzServers(t *testing.T) {
+	backends := map[string]*fakeBackend{"localhost:50051": fakeChannelzBackend()}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "table",
+			args: []string{"localhost:50051", "channelz", "servers"},
+			want: "Server ID   Listen Addresses    Calls(Started/Succeeded/Failed)   Last Call Started   \n" +
+				"1           [127.0.0.1:50051]   10/9/1                                                \n",
+		},
+		{
+			name: "json",
+			args: []string{"localhost:50051", "channelz", "servers", "-o", "json"},
+			want: `[
+  {
+    "ref": {
+      "serverId": "1"
+    },
+    "data": {
+      "callsStarted": "10",
+      "callsSucceeded": "9",
+      "callsFailed": "1"
+    },
+    "listenSocket": [
+      {
+        "socketId": "2"
+      }
+    ]
+  }
+]
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := runCommand(t, backends, test.args...)
+			if result.err != nil {
+				t.Fatalf("grpcdebug %v failed: %v\n%v", test.args, result.err, result.stderr)
+			}
+			if result.stdout != test.want {
+				t.Errorf("grpcdebug %v printed:\n%v\nwant:\n%v", test.args, result.stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestChannelzChannelNotFound(t *testing.T) {
+	backends := map[string]*fakeBackend{"localhost:50051": fakeChannelzBackend()}
+	result := runCommand(t, backends, "localhost:50051", "channelz", "channel", "1")
+	if result.err == nil {
+		t.Fatalf("grpcdebug channelz channel 1 succeeded, want error")
+	}
+}
@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/printer"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the grpcdebug_config.yaml file.",
+	Args:  cobra.NoArgs,
+}
+
+// configFileForEditing returns the config file with the highest precedence,
+// or the one in the user config directory, created on save, if there is none
+func configFileForEditing() (*config.Document, error) {
+	file, _, ok := config.FindConfigFile()
+	if !ok {
+		var err error
+		if file, err = config.UserConfigFile(); err != nil {
+			return nil, fmt.Errorf("failed to locate the user config directory: %v", err)
+		}
+	}
+	return config.OpenDocument(file)
+}
+
+// configFile is a config file in effect, as printed by the structured output
+// formats
+type configFile struct {
+	File   string `json:"file"`
+	Source string `json:"source"`
+	// Edited is set for the file edited by config set and delete
+	Edited bool `json:"edited"`
+}
+
+func configPathCommandRunWithError(cmd *cobra.Command, args []string) error {
+	var r report
+	table := r.newTable("", "File", "Source", "Edited")
+	files := []configFile{}
+	layers, err := config.Layers()
+	if len(layers) == 0 && err == nil {
+		file, err := config.UserConfigFile()
+		if err != nil {
+			return fmt.Errorf("failed to locate the user config directory: %v", err)
+		}
+		table.addRow(file, "not created yet", "*")
+		r.setData(append(files, configFile{File: file, Source: "not created yet", Edited: true}))
+		return printReport(&r)
+	}
+	edited, _, _ := config.FindConfigFile()
+	for _, layer := range layers {
+		var marker string
+		if layer.File == edited {
+			marker = "*"
+		}
+		table.addRow(layer.File, layer.Source, marker)
+		files = append(files, configFile{File: layer.File, Source: layer.Source, Edited: layer.File == edited})
+	}
+	r.setData(files)
+	if err := printReport(&r); err != nil {
+		return err
+	}
+	return err
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the config files in effect, by decreasing precedence.",
+	Args:  cobra.NoArgs,
+	RunE:  configPathCommandRunWithError,
+}
+
+// configServer is a server of the config, as printed by the structured
+// output formats
+type configServer struct {
+	Pattern     string `json:"pattern"`
+	RealAddress string `json:"realAddress,omitempty"`
+	Security    string `json:"security,omitempty"`
+}
+
+// configGroup is a group of the config, as printed by the structured output
+// formats
+type configGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+func configListCommandRunWithError(cmd *cobra.Command, args []string) error {
+	patterns, err := config.Servers()
+	if err != nil {
+		return err
+	}
+	var r report
+	table := r.newTable("", "Pattern", "Real Address", "Security")
+	servers := []configServer{}
+	for _, pattern := range patterns {
+		settings, err := config.ServerSettings(pattern, "")
+		if err != nil {
+			return err
+		}
+		// Unset settings are left blank
+		values := make(map[string]string)
+		for _, s := range settings {
+			values[s.Name] = s.Value
+		}
+		table.addRow(pattern, values["real_address"], values["security"])
+		servers = append(servers, configServer{Pattern: pattern, RealAddress: values["real_address"], Security: values["security"]})
+	}
+	groups, err := config.Groups()
+	if err != nil {
+		return err
+	}
+	data := printer.Object{{Name: "servers", Value: servers}}
+	if len(groups) > 0 {
+		table := r.newTable("", "Group", "Members")
+		var configGroups []configGroup
+		for _, group := range groups {
+			table.addRow(group.Name, strings.Join(group.Members, ", "))
+			configGroups = append(configGroups, configGroup{Name: group.Name, Members: group.Members})
+		}
+		data = append(data, printer.Field{Name: "groups", Value: configGroups})
+	}
+	r.setData(data)
+	return printReport(&r)
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the servers and the groups merged from the config files.",
+	Args:  cobra.NoArgs,
+	RunE:  configListCommandRunWithError,
+}
+
+// configSetting is an effective setting of a server, as printed by the
+// structured output formats
+type configSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+}
+
+func configGetCommandRunWithError(cmd *cobra.Command, args []string) error {
+	var setting string
+	if len(args) > 1 {
+		setting = args[1]
+	}
+	settings, err := config.ServerSettings(args[0], setting)
+	if err != nil {
+		return err
+	}
+	var r report
+	if len(settings) == 1 && settings[0].Name == setting {
+		// The value alone, so scripts can read it as is
+		r.newTable("").addRow(settings[0].Value)
+		r.setData(settings[0].Value)
+		return printReport(&r)
+	}
+	table := r.newTable("")
+	data := []configSetting{}
+	for _, s := range settings {
+		table.addRow(s.Name+":", s.Value, "# "+s.Origin.String())
+		data = append(data, configSetting{Name: s.Name, Value: s.Value, Origin: s.Origin.String()})
+	}
+	r.setData(data)
+	return printReport(&r)
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <pattern> [setting]",
+	Short: "Print the effective settings of a server, or one of them.",
+	Long: `Print the effective settings of a server, or one of them.
+
+The settings are merged from all config files, and each of them is printed with
+the file and line defining it. Nested settings are separated by dots, e.g.
+"ssh_jump.host" or "headers.x-api-key".`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: configGetCommandRunWithError,
+}
+
+func configSetCommandRunWithError(cmd *cobra.Command, args []string) error {
+	d, err := configFileForEditing()
+	if err != nil {
+		return err
+	}
+	if err := d.Set(args[0], args[1], args[2]); err != nil {
+		return err
+	}
+	if err := d.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Set %v of server %q in %v\n", args[1], args[0], d.Path)
+	return nil
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <pattern> <setting> <value>",
+	Short: "Set a setting of a server, adding the server if there is none.",
+	Long: `Set a setting of a server, adding the server if there is none.
+
+Nested settings are separated by dots, e.g. "ssh_jump.host" or
+"headers.x-api-key". The comments and the order of the config file are kept.
+If there is no config file, it is created in the user config directory.`,
+	Args: cobra.ExactArgs(3),
+	RunE: configSetCommandRunWithError,
+}
+
+func configDeleteCommandRunWithError(cmd *cobra.Command, args []string) error {
+	d, err := configFileForEditing()
+	if err != nil {
+		return err
+	}
+	var setting string
+	if len(args) > 1 {
+		setting = args[1]
+	}
+	if err := d.Delete(args[0], setting); err != nil {
+		return err
+	}
+	if err := d.Save(); err != nil {
+		return err
+	}
+	if setting == "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted server %q in %v\n", args[0], d.Path)
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted %v of server %q in %v\n", setting, args[0], d.Path)
+	}
+	return nil
+}
+
+var configDeleteCmd = &cobra.Command{
+	Use:   "delete <pattern> [setting]",
+	Short: "Delete a server of the config file, or one of its settings.",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  configDeleteCommandRunWithError,
+}
+
+func configValidateCommandRunWithError(cmd *cobra.Command, args []string) error {
+	var file string
+	if len(args) > 0 {
+		file = args[0]
+	}
+	layers, errs := config.Validate(file, config.Checks{Default: checkDefaultFlag, Alias: checkAlias})
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %v problem(s) in the grpcdebug config", len(errs))
+	}
+	if len(layers) == 0 {
+		fmt.Println("No grpcdebug config file found")
+		return nil
+	}
+	for _, layer := range layers {
+		fmt.Printf("%v is valid\n", layer.File)
+	}
+	return nil
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Report all problems of the config files in effect, or the given one.",
+	Long: `Report all problems of the config files in effect, or the given one, and
+of the files they include.
+
+Besides syntax errors, it reports unknown settings, invalid values, and files
+referenced by the server configs which don't exist. Relative paths are resolved
+against the directory of the config file.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: configValidateCommandRunWithError,
+}
+
+func configUseContextCommandRunWithError(cmd *cobra.Command, args []string) error {
+	ok, err := config.HasContext(args[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no context %q, see \"grpcdebug config get-contexts\"", args[0])
+	}
+	d, err := configFileForEditing()
+	if err != nil {
+		return err
+	}
+	d.SetCurrentContext(args[0])
+	if err := d.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Switched to context %q in %v\n", args[0], d.Path)
+	return nil
+}
+
+var configUseContextCmd = &cobra.Command{
+	Use:   "use-context <name>",
+	Short: "Set the current context, whose target is used without --target.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  configUseContextCommandRunWithError,
+}
+
+// configContext is a context, as printed by the structured output formats
+type configContext struct {
+	Name    string `json:"name"`
+	Target  string `json:"target"`
+	Origin  string `json:"origin"`
+	Current bool   `json:"current"`
+}
+
+func configCurrentContextCommandRunWithError(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	context, ok := cfg.CurrentContext()
+	if !ok {
+		return fmt.Errorf("no current context")
+	}
+	var r report
+	r.newTable("").addRow(context.Name)
+	r.setData(configContext{Name: context.Name, Target: context.Target, Origin: context.Origin.String(), Current: true})
+	return printReport(&r)
+}
+
+var configCurrentContextCmd = &cobra.Command{
+	Use:   "current-context",
+	Short: "Print the name of the current context.",
+	Args:  cobra.NoArgs,
+	RunE:  configCurrentContextCommandRunWithError,
+}
+
+func configGetContextsCommandRunWithError(cmd *cobra.Command, args []string) error {
+	contexts, current, err := config.Contexts()
+	if err != nil {
+		return err
+	}
+	var r report
+	table := r.newTable("", "Current", "Name", "Target", "Origin")
+	data := []configContext{}
+	for _, context := range contexts {
+		var marker string
+		if context.Name == current {
+			marker = "*"
+		}
+		table.addRow(marker, context.Name, context.Target, context.Origin)
+		data = append(data, configContext{Name: context.Name, Target: context.Target, Origin: context.Origin.String(), Current: context.Name == current})
+	}
+	r.setData(data)
+	return printReport(&r)
+}
+
+var configGetContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List the contexts, marking the current one.",
+	Args:  cobra.NoArgs,
+	RunE:  configGetContextsCommandRunWithError,
+}
+
+func configSetContextCommandRunWithError(cmd *cobra.Command, args []string) error {
+	d, err := configFileForEditing()
+	if err != nil {
+		return err
+	}
+	d.SetContext(args[0], args[1])
+	if err := d.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Set the target of context %q to %v in %v\n", args[0], args[1], d.Path)
+	return nil
+}
+
+var configSetContextCmd = &cobra.Command{
+	Use:   "set-context <name> <target>",
+	Short: "Set the target of a context, adding the context if there is none.",
+	Long: `Set the target of a context, adding the context if there is none.
+
+The target is what --target accepts: an address, a server pattern or a group,
+or a comma separated list of them.`,
+	Args: cobra.ExactArgs(2),
+	RunE: configSetContextCommandRunWithError,
+}
+
+func configDeleteContextCommandRunWithError(cmd *cobra.Command, args []string) error {
+	d, err := configFileForEditing()
+	if err != nil {
+		return err
+	}
+	if err := d.DeleteContext(args[0]); err != nil {
+		return err
+	}
+	if err := d.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Deleted context %q in %v\n", args[0], d.Path)
+	return nil
+}
+
+var configDeleteContextCmd = &cobra.Command{
+	Use:   "delete-context <name>",
+	Short: "Delete a context of the config file, unsetting it if it's current.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  configDeleteContextCommandRunWithError,
+}
+
+func init() {
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configDeleteCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configUseContextCmd)
+	configCmd.AddCommand(configCurrentContextCmd)
+	configCmd.AddCommand(configGetContextsCmd)
+	configCmd.AddCommand(configSetContextCmd)
+	configCmd.AddCommand(configDeleteContextCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadAliases loads the mapping from alias names to command lines
+func (f *configFile) loadAliases(node *yaml.Node) []error {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return []error{f.errorAt(node, "aliases must be a mapping from names to command lines")}
+	}
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if _, ok := f.aliases[key.Value]; ok {
+			errs = append(errs, f.errorAt(key, "duplicate alias %q", key.Value))
+			continue
+		}
+		if key.Value == "" || strings.ContainsAny(key.Value, " \t") || strings.HasPrefix(key.Value, "-") {
+			errs = append(errs, f.errorAt(key, "invalid alias name %q", key.Value))
+			continue
+		}
+		alias := &Alias{Name: key.Value, Origin: Origin{f.path, key.Line}}
+		switch value.Kind {
+		case yaml.ScalarNode:
+			alias.Command = value.Value
+		case yaml.MappingNode:
+			errs = append(errs, f.checkFields(value, reflect.TypeOf(Alias{}), fmt.Sprintf("alias %q", key.Value))...)
+			if err := value.Decode(alias); err != nil {
+				errs = append(errs, f.yamlErrors(err, value)...)
+				continue
+			}
+		default:
+			errs = append(errs, f.errorAt(value, "alias %q must be a command line, or a mapping with a command", key.Value))
+			continue
+		}
+		if len(alias.Args()) == 0 {
+			errs = append(errs, f.errorAt(value, "alias %q has no command", key.Value))
+			continue
+		}
+		f.aliases[key.Value] = alias
+		f.aliasNames = append(f.aliasNames, key.Value)
+	}
+	return errs
+}
+
+// mergeAliases replaces the aliases with the ones of the file
+func (l *layeredConfig) mergeAliases(f *configFile) {
+	for _, name := range f.aliasNames {
+		if _, ok := l.aliases[name]; !ok {
+			l.aliasNames = append(l.aliasNames, name)
+		}
+		l.aliases[name] = f.aliases[name]
+	}
+}
+
+// checkAliases verifies the alias names don't shadow server patterns, which
+// can be given as a target before the command
+func (l *layeredConfig) checkAliases() []error {
+	var errs []error
+	for _, name := range l.aliasNames {
+		if _, ok := l.servers[name]; ok {
+			alias := l.aliases[name]
+			errs = append(errs, &Error{File: alias.Origin.File, Line: alias.Origin.Line, Msg: fmt.Sprintf("alias %q has the name of a server", name)})
+		}
+	}
+	return errs
+}
+
+// Args returns the arguments of the command line of the alias, which are
+// separated by whitespace
+func (a *Alias) Args() []string {
+	return strings.Fields(a.Command)
+}
+
+// Aliases returns the merged aliases, in the order they are first defined.
+// They are returned even if the config is invalid, apart from those which
+// have the name of a server.
+func Aliases() ([]*Alias, error) {
+	l, errs := loadLayers(baseLayers())
+	var aliases []*Alias
+	for _, name := range l.aliasNames {
+		if _, ok := l.servers[name]; ok {
+			continue
+		}
+		aliases = append(aliases, l.aliases[name])
+	}
+	if len(errs) > 0 {
+		return aliases, fmt.Errorf("invalid grpcdebug config:\n%v", errors.Join(errs...))
+	}
+	return aliases, nil
+}
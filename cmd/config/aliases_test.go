@@ -0,0 +1,25 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAliasNamedLikeServer(t *testing.T) {
+	file := useConfig(t, "servers:\n  prod:\naliases:\n  prod: health\n  check: health -o csv\n")
+	_, errs := Validate("", Checks{Default: func(string) error { return nil }, Alias: func(string, []string) error { return nil }})
+	want := file + `:4: alias "prod" has the name of a server`
+	if got := strings.Join(errorStrings(errs), "\n"); got != want {
+		t.Errorf("Validate() = %q, want %q", got, want)
+	}
+	// Left out of the commands
+	aliases, _ := Aliases()
+	var names []string
+	for _, alias := range aliases {
+		names = append(names, alias.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"check"}) {
+		t.Errorf("Aliases() = %v, want [check]", names)
+	}
+}
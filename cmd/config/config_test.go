@@ -0,0 +1,227 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes the file in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %v: %v", file, err)
+	}
+	return file
+}
+
+// useConfig makes the config file with the content the only one in effect,
+// and returns its path
+func useConfig(t *testing.T, content string) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	file := writeFile(t, t.TempDir(), configFileName, content)
+	t.Setenv(grpcdebugServerConfigEnvName, file)
+	return file
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		patterns []string
+		target   string
+		want     string
+	}{
+		{
+			name:     "exact over glob and regex",
+			patterns: []string{"a.prod:50051", "a.*:50051", "regex:^a"},
+			target:   "a.prod:50051",
+			want:     "a.prod:50051",
+		},
+		{
+			name:     "glob over regex",
+			patterns: []string{"*", "regex:^a.prod:50051$"},
+			target:   "a.prod:50051",
+			want:     "*",
+		},
+		{
+			name:     "glob with more literals",
+			patterns: []string{"*:*", "*.prod:*", "a.*:50051"},
+			target:   "a.prod:50051",
+			want:     "a.*:50051",
+		},
+		{
+			name:     "glob with fewer wildcards on equal literals",
+			patterns: []string{"a*b*", "ab*"},
+			target:   "abc",
+			want:     "ab*",
+		},
+		{
+			name:     "glob in lexical order on a tie",
+			patterns: []string{"a[b]c", "a?c"},
+			target:   "abc",
+			want:     "a?c",
+		},
+		{
+			name:     "glob not matching",
+			patterns: []string{"*.staging:*", "regex:prod"},
+			target:   "a.prod:50051",
+			want:     "regex:prod",
+		},
+		{
+			name:     "regexes in lexical order",
+			patterns: []string{"regex:^a.prod", "regex:prod", "regex:^a"},
+			target:   "a.prod:50051",
+			want:     "regex:^a",
+		},
+		{
+			name:     "regex not matching",
+			patterns: []string{"regex:^b", "regex:staging"},
+			target:   "a.prod:50051",
+			want:     "",
+		},
+		{
+			name:   "no pattern",
+			target: "a.prod:50051",
+			want:   "",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			configs := make(map[string]ServerConfig)
+			for _, pattern := range test.patterns {
+				configs[pattern] = ServerConfig{}
+			}
+			// The order of the map doesn't matter
+			for i := 0; i < 10; i++ {
+				got, ok := matchPattern(configs, test.target)
+				if got != test.want || ok != (test.want != "") {
+					t.Fatalf("matchPattern(%v, %q) = %q, %v, want %q", test.patterns, test.target, got, ok, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandRealAddress(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		pattern     string
+		target      string
+		realAddress string
+		want        string
+	}{
+		{
+			name:    "defaults to the target",
+			pattern: "*.prod:*",
+			target:  "a.prod:50051",
+			want:    "a.prod:50051",
+		},
+		{
+			name:        "glob keeps the real address",
+			pattern:     "*.prod:*",
+			target:      "a.prod:50051",
+			realAddress: "$1.internal:50051",
+			want:        "$1.internal:50051",
+		},
+		{
+			name:        "numbered groups",
+			pattern:     `regex:^(\w+)\.prod:(\d+)$`,
+			target:      "a.prod:50051",
+			realAddress: "$1.internal:$2",
+			want:        "a.internal:50051",
+		},
+		{
+			name:        "named groups",
+			pattern:     `regex:^(?P<host>\w+)\.prod:(?P<port>\d+)$`,
+			target:      "a.prod:50051",
+			realAddress: "${host}.internal:${port}",
+			want:        "a.internal:50051",
+		},
+		{
+			name:        "missing group",
+			pattern:     `regex:^(\w+)\.prod`,
+			target:      "a.prod:50051",
+			realAddress: "$1.internal:$2",
+			want:        "a.internal:",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := expandRealAddress(test.pattern, test.target, ServerConfig{RealAddress: test.realAddress})
+			if got.RealAddress != test.want {
+				t.Errorf("expandRealAddress(%q, %q) = %q, want %q", test.pattern, test.target, got.RealAddress, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		wantErr string
+	}{
+		{pattern: "localhost:50051"},
+		{pattern: "*.prod:*"},
+		{pattern: `regex:^(\w+)\.prod$`},
+		{pattern: "regex:^(a", wantErr: `invalid regex server pattern "regex:^(a"`},
+		{pattern: "regex:a**", wantErr: `invalid regex server pattern "regex:a**"`},
+		{pattern: "[a-", wantErr: `invalid glob server pattern "[a-"`},
+	} {
+		err := checkPattern(test.pattern)
+		if test.wantErr == "" && err != nil {
+			t.Errorf("checkPattern(%q) = %v, want no error", test.pattern, err)
+		}
+		if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("checkPattern(%q) = %v, want error containing %q", test.pattern, err, test.wantErr)
+		}
+	}
+}
+
+func TestLoadInvalidRegex(t *testing.T) {
+	file := useConfig(t, `servers:
+  "regex:^(a":
+    real_address: localhost:50051
+`)
+	_, err := Load()
+	want := file + `:2:3: invalid regex server pattern "regex:^(a"`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() = %v, want error containing %q", err, want)
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	useConfig(t, `servers:
+  "regex:^(\\w+)\\.prod:(\\d+)$":
+    real_address: $1.internal:$2
+    security: tls
+  "*.prod:443":
+    real_address: glob.internal:443
+  b.prod:50051:
+    security: insecure
+`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	for _, test := range []struct {
+		target          string
+		wantRealAddress string
+	}{
+		{"a.prod:443", "glob.internal:443"},
+		{"a.prod:50051", "a.internal:50051"},
+		{"b.prod:50051", "b.prod:50051"},
+		{"c.staging:50051", "c.staging:50051"},
+	} {
+		c, err := cfg.ServerConfig(test.target)
+		if err != nil {
+			t.Fatalf("ServerConfig(%q) failed: %v", test.target, err)
+		}
+		if c.RealAddress != test.wantRealAddress {
+			t.Errorf("ServerConfig(%q).RealAddress = %q, want %q", test.target, c.RealAddress, test.wantRealAddress)
+		}
+	}
+}
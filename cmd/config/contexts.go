@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadContexts loads the mapping from context names to contexts
+func (f *configFile) loadContexts(node *yaml.Node) []error {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return []error{f.errorAt(node, "contexts must be a mapping from names to contexts")}
+	}
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if _, ok := f.contexts[key.Value]; ok {
+			errs = append(errs, f.errorAt(key, "duplicate context %q", key.Value))
+			continue
+		}
+		if value.Kind != yaml.MappingNode {
+			errs = append(errs, f.errorAt(value, "context %q must be a mapping with a target", key.Value))
+			continue
+		}
+		errs = append(errs, f.checkFields(value, reflect.TypeOf(Context{}), fmt.Sprintf("context %q", key.Value))...)
+		context := &Context{Name: key.Value, Origin: Origin{f.path, key.Line}}
+		if err := value.Decode(context); err != nil {
+			errs = append(errs, f.yamlErrors(err, value)...)
+			continue
+		}
+		if strings.TrimSpace(context.Target) == "" {
+			errs = append(errs, f.errorAt(value, "context %q has no target", key.Value))
+			continue
+		}
+		f.contexts[key.Value] = context
+		f.contextNames = append(f.contextNames, key.Value)
+	}
+	return errs
+}
+
+func (f *configFile) loadCurrentContext(node *yaml.Node) []error {
+	if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+		return []error{f.errorAt(node, "current_context must be the name of a context")}
+	}
+	f.currentContext = &Context{Name: node.Value, Origin: Origin{f.path, node.Line}}
+	return nil
+}
+
+// mergeContexts replaces the contexts, and the current context, with the ones
+// of the file
+func (l *layeredConfig) mergeContexts(f *configFile) {
+	for _, name := range f.contextNames {
+		if _, ok := l.contexts[name]; !ok {
+			l.contextNames = append(l.contextNames, name)
+		}
+		l.contexts[name] = f.contexts[name]
+	}
+	if f.currentContext != nil {
+		l.currentContext = f.currentContext
+	}
+}
+
+// checkContexts verifies the current context exists
+func (l *layeredConfig) checkContexts() []error {
+	if current := l.currentContext; current != nil {
+		if _, ok := l.contexts[current.Name]; !ok {
+			return []error{&Error{File: current.Origin.File, Line: current.Origin.Line, Msg: fmt.Sprintf("current context %q is not defined", current.Name)}}
+		}
+	}
+	return nil
+}
+
+// contextTargets verifies the groups in the target of the context exist
+func (l *layeredConfig) contextTargets(name string) (*Context, []string, error) {
+	context := l.contexts[name]
+	var targets []string
+	for _, target := range strings.Split(context.Target, ",") {
+		target = strings.TrimSpace(target)
+		if _, ok := l.groups[target]; ok {
+			if _, err := l.expandGroup(target, make(map[string]bool)); err != nil {
+				return nil, nil, err
+			}
+		}
+		targets = append(targets, target)
+	}
+	return context, targets, nil
+}
+
+// Contexts returns the merged contexts, in the order they are first defined,
+// and the name of the current context, if any
+func Contexts() ([]*Context, string, error) {
+	l, err := loadConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	var contexts []*Context
+	for _, name := range l.contextNames {
+		contexts = append(contexts, l.contexts[name])
+	}
+	var current string
+	if l.currentContext != nil {
+		current = l.currentContext.Name
+	}
+	return contexts, current, nil
+}
+
+// CurrentContext returns the current context, or false if there is none
+func (c *Config) CurrentContext() (*Context, bool) {
+	if c.l.currentContext == nil {
+		return nil, false
+	}
+	return c.l.contexts[c.l.currentContext.Name], true
+}
+
+// HasContext reports whether the context is defined in any config file
+func HasContext(name string) (bool, error) {
+	l, err := loadConfig()
+	if err != nil {
+		return false, err
+	}
+	_, ok := l.contexts[name]
+	return ok, nil
+}
+
+// SetContext sets the target of the context, adding the context if there is
+// none
+func (d *Document) SetContext(name, target string) {
+	context := child(child(d.root.Content[0], "contexts", true), name, true)
+	setScalar(context, "target", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: target})
+}
+
+// DeleteContext removes the context, and unsets it if it's the current one
+func (d *Document) DeleteContext(name string) error {
+	contexts := child(d.root.Content[0], "contexts", false)
+	i := -1
+	if contexts != nil {
+		i = mappingIndex(contexts, name)
+	}
+	if i < 0 {
+		return fmt.Errorf("no context %q in %v", name, d.Path)
+	}
+	contexts.Content = append(contexts.Content[:i], contexts.Content[i+2:]...)
+	if current := child(d.root.Content[0], "current_context", false); current != nil && current.Value == name {
+		d.SetCurrentContext("")
+	}
+	return nil
+}
+
+// SetCurrentContext sets the current context, or unsets it if name is empty
+func (d *Document) SetCurrentContext(name string) {
+	document := d.root.Content[0]
+	if name == "" {
+		if i := mappingIndex(document, "current_context"); i >= 0 {
+			document.Content = append(document.Content[:i], document.Content[i+2:]...)
+		}
+		return
+	}
+	setScalar(document, "current_context", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name})
+}
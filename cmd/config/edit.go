@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a config file edited in place, keeping its comments and the
+// order of its servers and settings
+type Document struct {
+	Path string
+	root yaml.Node
+}
+
+// OpenDocument loads the config file for editing. A file which doesn't exist
+// is created on Save.
+func OpenDocument(file string) (*Document, error) {
+	d := &Document{Path: file}
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &d.root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %v: %v", file, err)
+	}
+	if len(d.root.Content) == 0 {
+		d.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if d.root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %v is not a mapping", file)
+	}
+	return d, nil
+}
+
+// mappingIndex returns the index of the key in the mapping node, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// child returns the value of the key in the mapping node, adding an empty
+// mapping if create is set
+func child(node *yaml.Node, key string, create bool) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		value := node.Content[i+1]
+		if create && value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+			// e.g. "servers:" without any server
+			*value = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		return value
+	}
+	if !create {
+		return nil
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return value
+}
+
+func (d *Document) servers(create bool) *yaml.Node {
+	return child(d.root.Content[0], "servers", create)
+}
+
+func (d *Document) server(pattern string) (*yaml.Node, error) {
+	servers := d.servers(false)
+	if servers == nil || servers.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("no server %q in %v", pattern, d.Path)
+	}
+	server := child(servers, pattern, false)
+	if server == nil {
+		return nil, fmt.Errorf("no server %q in %v", pattern, d.Path)
+	}
+	return server, nil
+}
+
+// settingType returns the type of the setting of a server config, e.g.
+// "ssh_jump.host" or "headers.x-api-key"
+func settingType(setting string) (reflect.Type, error) {
+	t := reflect.TypeOf(ServerConfig{})
+	for _, key := range strings.Split(setting, ".") {
+		switch t.Kind() {
+		case reflect.Ptr:
+			t = t.Elem()
+			fallthrough
+		case reflect.Struct:
+			fieldType, ok := yamlFields(t)[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown setting %q", setting)
+			}
+			t = fieldType
+		case reflect.Map:
+			if key == "" {
+				return nil, fmt.Errorf("malformed setting %q", setting)
+			}
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("unknown setting %q", setting)
+		}
+	}
+	return t, nil
+}
+
+// Set sets the setting of the server, adding the server if there is none
+func (d *Document) Set(pattern, setting, value string) error {
+	t, err := settingType(setting)
+	if err != nil {
+		return err
+	}
+	tag := "!!str"
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid duration %q of %v: %v", value, setting, err)
+		}
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q of %v: %v", value, setting, err)
+		}
+		tag, value = "!!bool", strconv.FormatBool(b)
+	case t.Kind() != reflect.String:
+		return fmt.Errorf("%v is a group of settings, please set its settings one by one", setting)
+	}
+	node := child(d.servers(true), pattern, true)
+	keys := strings.Split(setting, ".")
+	for _, key := range keys[:len(keys)-1] {
+		node = child(node, key, true)
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("can't set %v of server %q, whose parent is not a mapping", setting, pattern)
+	}
+	setScalar(node, keys[len(keys)-1], &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value})
+	return nil
+}
+
+// setScalar sets the value of the key in the mapping node, keeping the
+// comments of the replaced value
+func setScalar(node *yaml.Node, key string, scalar *yaml.Node) {
+	if i := mappingIndex(node, key); i >= 0 {
+		scalar.HeadComment = node.Content[i+1].HeadComment
+		scalar.LineComment = node.Content[i+1].LineComment
+		node.Content[i+1] = scalar
+		return
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, scalar)
+}
+
+// Delete removes the setting of the server, or the whole server if setting is
+// empty
+func (d *Document) Delete(pattern, setting string) error {
+	node, err := d.server(pattern)
+	if err != nil {
+		return err
+	}
+	if setting == "" {
+		node = d.servers(false)
+		i := mappingIndex(node, pattern)
+		node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		return nil
+	}
+	if _, err := settingType(setting); err != nil {
+		return err
+	}
+	keys := strings.Split(setting, ".")
+	for _, key := range keys[:len(keys)-1] {
+		if node = child(node, key, false); node == nil {
+			return fmt.Errorf("setting %q of server %q is not set", setting, pattern)
+		}
+	}
+	i := mappingIndex(node, keys[len(keys)-1])
+	if i < 0 {
+		return fmt.Errorf("setting %q of server %q is not set", setting, pattern)
+	}
+	node.Content = append(node.Content[:i], node.Content[i+2:]...)
+	return nil
+}
+
+// renameFile replaces the config file with the written one, it can be
+// replaced by tests to fail
+var renameFile = os.Rename
+
+// Save validates the edited config, and writes it back to the file
+func (d *Document) Save() error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&d.root); err != nil {
+		return err
+	}
+	encoder.Close()
+	if _, errs := loadConfigData(d.Path, buf.Bytes()); len(errs) > 0 {
+		return fmt.Errorf("refusing to save an invalid config:\n%v", errors.Join(errs...))
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(d.Path); err == nil {
+		mode = info.Mode()
+	}
+	if err := os.MkdirAll(filepath.Dir(d.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	// Replaces the file at once, so it is never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(d.Path), ".grpcdebug_config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := buf.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	if err := renameFile(tmp.Name(), d.Path); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const commentedConfig = `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.internal:50051 # behind the load balancer
+    security: tls
+    credential_file: ca.pem
+  # Local test server
+  localhost:50051:
+    rpc_timeout: 30s
+groups:
+  # Every server
+  all:
+    - prod
+    - localhost:50051 # kept
+`
+
+// readFile returns the content of the file
+func readFile(t *testing.T, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read %v: %v", file, err)
+	}
+	return string(data)
+}
+
+func TestDocumentSet(t *testing.T) {
+	file := writeFile(t, t.TempDir(), configFileName, commentedConfig)
+	d, err := OpenDocument(file)
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	for _, set := range [][3]string{
+		{"prod", "real_address", "prod.example.com:50051"},
+		{"prod", "ssh_jump.host", "bastion"},
+		{"localhost:50051", "allow_insecure_token", "TRUE"},
+		{"staging", "security", "insecure"},
+	} {
+		if err := d.Set(set[0], set[1], set[2]); err != nil {
+			t.Fatalf("Set(%q, %q, %q) failed: %v", set[0], set[1], set[2], err)
+		}
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	want := `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.example.com:50051 # behind the load balancer
+    security: tls
+    credential_file: ca.pem
+    ssh_jump:
+      host: bastion
+  # Local test server
+  localhost:50051:
+    rpc_timeout: 30s
+    allow_insecure_token: true
+  staging:
+    security: insecure
+groups:
+  # Every server
+  all:
+    - prod
+    - localhost:50051 # kept
+`
+	if got := readFile(t, file); got != want {
+		t.Errorf("saved config:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestDocumentDelete(t *testing.T) {
+	file := writeFile(t, t.TempDir(), configFileName, commentedConfig)
+	d, err := OpenDocument(file)
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	if err := d.Delete("prod", "credential_file"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := d.Delete("prod", "security"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := d.Delete("localhost:50051", ""); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := d.Delete("prod", "proxy"); err == nil || err.Error() != `setting "proxy" of server "prod" is not set` {
+		t.Errorf("Delete() of an unset setting = %v, want error", err)
+	}
+	if err := d.Delete("staging", ""); err == nil || !strings.Contains(err.Error(), `no server "staging"`) {
+		t.Errorf("Delete() of a missing server = %v, want error", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	want := `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.internal:50051 # behind the load balancer
+groups:
+  # Every server
+  all:
+    - prod
+    - localhost:50051 # kept
+`
+	if got := readFile(t, file); got != want {
+		t.Errorf("saved config:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestDocumentSetInvalid(t *testing.T) {
+	d, err := OpenDocument(filepath.Join(t.TempDir(), configFileName))
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	for _, test := range []struct {
+		setting string
+		value   string
+		wantErr string
+	}{
+		{"real_adress", "localhost:50052", `unknown setting "real_adress"`},
+		{"connect_timeout", "soon", `invalid duration "soon" of connect_timeout`},
+		{"allow_insecure_token", "maybe", `invalid boolean "maybe" of allow_insecure_token`},
+		{"ssh_jump", "bastion", "ssh_jump is a group of settings"},
+		{"headers.", "value", `malformed setting "headers."`},
+	} {
+		if err := d.Set("prod", test.setting, test.value); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("Set(%q, %q) = %v, want error containing %q", test.setting, test.value, err, test.wantErr)
+		}
+	}
+}
+
+func TestDocumentSaveInvalid(t *testing.T) {
+	file := writeFile(t, t.TempDir(), configFileName, commentedConfig)
+	d, err := OpenDocument(file)
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	if err := d.Set("prod", "security", "plaintext"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	err = d.Save()
+	if err == nil || !strings.Contains(err.Error(), `refusing to save an invalid config`) || !strings.Contains(err.Error(), `invalid security mode "plaintext"`) {
+		t.Errorf("Save() = %v, want error of the invalid security mode", err)
+	}
+	if got := readFile(t, file); got != commentedConfig {
+		t.Errorf("config after failed save:\n%v\nwant it unchanged", got)
+	}
+}
+
+func TestDocumentSaveFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, configFileName, commentedConfig)
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	d, err := OpenDocument(file)
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	if err := d.Set("prod", "real_address", "prod.example.com:50051"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	renameFile = func(string, string) error { return errors.New("disk full") }
+	defer func() { renameFile = os.Rename }()
+	if err := d.Save(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Save() = %v, want error of writing", err)
+	}
+	if got := readFile(t, file); got != commentedConfig {
+		t.Errorf("config after failed save:\n%v\nwant it unchanged", got)
+	}
+	// The temporary file is removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to list %v: %v", dir, err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("files after failed save = %v, want only the config", entries)
+	}
+}
+
+func TestDocumentSaveKeepsMode(t *testing.T) {
+	file := writeFile(t, t.TempDir(), configFileName, commentedConfig)
+	if err := os.Chmod(file, 0640); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	d, err := OpenDocument(file)
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	if err := d.Set("prod", "proxy", "direct"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("failed to stat: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode after save = %v, want 0640", info.Mode().Perm())
+	}
+}
+
+func TestOpenDocumentMissing(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "grpcdebug", configFileName)
+	d, err := OpenDocument(file)
+	if err != nil {
+		t.Fatalf("OpenDocument() failed: %v", err)
+	}
+	if err := d.Set("prod", "real_address", "prod.internal:50051"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if got, want := readFile(t, file), "servers:\n  prod:\n    real_address: prod.internal:50051\n"; got != want {
+		t.Errorf("created config:\n%v\nwant:\n%v", got, want)
+	}
+}
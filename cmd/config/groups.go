@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Group is a named list of targets, e.g. a fleet of servers
+type Group struct {
+	Name string
+	// Members are targets, server patterns, or other groups. Glob members,
+	// e.g. "fe-*", stand for the servers of the config matching them.
+	Members []string
+	Origin  Origin
+}
+
+// loadGroups loads the mapping from group names to their members
+func (f *configFile) loadGroups(node *yaml.Node) []error {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return []error{f.errorAt(node, "groups must be a mapping from names to lists of members")}
+	}
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if _, ok := f.groups[key.Value]; ok {
+			errs = append(errs, f.errorAt(key, "duplicate group %q", key.Value))
+			continue
+		}
+		if value.Kind != yaml.SequenceNode || len(value.Content) == 0 {
+			errs = append(errs, f.errorAt(value, "group %q must be a non-empty list of members", key.Value))
+			continue
+		}
+		group := &Group{Name: key.Value, Origin: Origin{f.path, key.Line}}
+		for _, member := range value.Content {
+			if member.Kind != yaml.ScalarNode || member.Value == "" {
+				errs = append(errs, f.errorAt(member, "member of group %q must be a target", key.Value))
+				continue
+			}
+			if _, err := path.Match(member.Value, ""); err != nil {
+				errs = append(errs, f.errorAt(member, "invalid glob member %q of group %q: %v", member.Value, key.Value, err))
+				continue
+			}
+			group.Members = append(group.Members, member.Value)
+		}
+		f.groups[key.Value] = group
+		f.groupNames = append(f.groupNames, key.Value)
+	}
+	return errs
+}
+
+// mergeGroups replaces the groups with the ones of the file
+func (l *layeredConfig) mergeGroups(f *configFile) {
+	for _, name := range f.groupNames {
+		if _, ok := l.groups[name]; !ok {
+			l.groupNames = append(l.groupNames, name)
+		}
+		l.groups[name] = f.groups[name]
+	}
+}
+
+// checkGroups verifies the group names don't shadow server patterns
+func (l *layeredConfig) checkGroups() []error {
+	var errs []error
+	for _, name := range l.groupNames {
+		if _, ok := l.servers[name]; ok {
+			group := l.groups[name]
+			errs = append(errs, &Error{File: group.Origin.File, Line: group.Origin.Line, Msg: fmt.Sprintf("group %q has the name of a server", name)})
+		}
+	}
+	return errs
+}
+
+// expandGroup returns the members of the group, with the nested groups and
+// the glob members expanded
+func (l *layeredConfig) expandGroup(name string, expanding map[string]bool) ([]string, error) {
+	group := l.groups[name]
+	if expanding[name] {
+		return nil, &Error{File: group.Origin.File, Line: group.Origin.Line, Msg: fmt.Sprintf("group %q includes itself", name)}
+	}
+	expanding[name] = true
+	defer delete(expanding, name)
+	var members []string
+	for _, member := range group.Members {
+		if _, ok := l.groups[member]; ok {
+			nested, err := l.expandGroup(member, expanding)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, nested...)
+			continue
+		}
+		if !isGlobPattern(member) {
+			members = append(members, member)
+			continue
+		}
+		matched := false
+		for _, pattern := range l.patterns {
+			if isGlobPattern(pattern) || strings.HasPrefix(pattern, regexPatternPrefix) {
+				continue
+			}
+			if ok, _ := path.Match(member, pattern); ok {
+				members = append(members, pattern)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, &Error{File: group.Origin.File, Line: group.Origin.Line, Msg: fmt.Sprintf("member %q of group %q matches no server", member, name)}
+		}
+	}
+	return members, nil
+}
+
+// ExpandGroup returns the members of the group, with the nested groups and
+// the glob members expanded, or false if there is no such group
+func (c *Config) ExpandGroup(name string) ([]string, bool, error) {
+	if _, ok := c.l.groups[name]; !ok {
+		return nil, false, nil
+	}
+	members, err := c.l.expandGroup(name, make(map[string]bool))
+	return members, true, err
+}
+
+// Groups returns the merged groups, in the order they are first defined
+func Groups() ([]*Group, error) {
+	l, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var groups []*Group
+	for _, name := range l.groupNames {
+		groups = append(groups, l.groups[name])
+	}
+	return groups, nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const groupsConfig = `servers:
+  fe-1.prod:50051:
+  fe-2.prod:50051:
+  be-1.prod:50051:
+  "*.staging:*":
+  "regex:^fe-.*":
+groups:
+  frontends:
+    - fe-*
+  backends:
+    - be-1.prod:50051
+    - db.prod:5432
+  prod:
+    - frontends
+    - backends
+    - fe-1.prod:50051
+`
+
+func TestExpandGroup(t *testing.T) {
+	useConfig(t, groupsConfig)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	for _, test := range []struct {
+		name   string
+		want   []string
+		wantOk bool
+	}{
+		// Glob members only match the servers named literally
+		{"frontends", []string{"fe-1.prod:50051", "fe-2.prod:50051"}, true},
+		// Members which aren't servers are kept
+		{"backends", []string{"be-1.prod:50051", "db.prod:5432"}, true},
+		// Duplicates are dropped by the commands, which parse the targets
+		{"prod", []string{"fe-1.prod:50051", "fe-2.prod:50051", "be-1.prod:50051", "db.prod:5432", "fe-1.prod:50051"}, true},
+		{"fe-1.prod:50051", nil, false},
+	} {
+		members, ok, err := cfg.ExpandGroup(test.name)
+		if err != nil {
+			t.Fatalf("ExpandGroup(%q) failed: %v", test.name, err)
+		}
+		if ok != test.wantOk || !reflect.DeepEqual(members, test.want) {
+			t.Errorf("ExpandGroup(%q) = %v, %v, want %v, %v", test.name, members, ok, test.want, test.wantOk)
+		}
+	}
+}
+
+func TestExpandGroupOverridden(t *testing.T) {
+	dir := t.TempDir()
+	included := writeFile(t, dir, "included.yaml", "groups:\n  prod:\n    - a:50051\n    - b:50051\n")
+	main := useConfig(t, "include: "+included+"\ngroups:\n  prod:\n    - c:50051\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	// Replaced as a whole, not merged
+	members, _, err := cfg.ExpandGroup("prod")
+	if err != nil {
+		t.Fatalf("ExpandGroup() failed: %v", err)
+	}
+	if !reflect.DeepEqual(members, []string{"c:50051"}) {
+		t.Errorf("ExpandGroup() = %v, want [c:50051] of %v", members, main)
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "cycle",
+			content: "groups:\n  a:\n    - b\n  b:\n    - a\n",
+			want:    `:2: group "a" includes itself`,
+		},
+		{
+			name:    "glob matching no server",
+			content: "servers:\n  fe-1:50051:\ngroups:\n  backends:\n    - be-*\n",
+			want:    `:4: member "be-*" of group "backends" matches no server`,
+		},
+		{
+			name:    "invalid glob",
+			content: "groups:\n  backends:\n    - be-[\n",
+			want:    `:3:7: invalid glob member "be-[" of group "backends"`,
+		},
+		{
+			name:    "empty",
+			content: "groups:\n  backends: []\n",
+			want:    `:2:13: group "backends" must be a non-empty list of members`,
+		},
+		{
+			name:    "name of a server",
+			content: "servers:\n  prod:\ngroups:\n  prod:\n    - a:50051\n",
+			want:    `:4: group "prod" has the name of a server`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := useConfig(t, test.content)
+			_, errs := Validate("", Checks{})
+			var found bool
+			for _, err := range errs {
+				found = found || strings.HasPrefix(err.Error(), file+test.want)
+			}
+			if !found {
+				t.Errorf("Validate() = %q, want an error %q", errorStrings(errs), file+test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+	"gopkg.in/yaml.v3"
+)
+
+// Origin is where a setting is defined
+type Origin struct {
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%v:%v", o.File, o.Line)
+}
+
+// Setting is an effective setting of a server, e.g. "ssh_jump.host", and
+// where it is defined
+type Setting struct {
+	Name   string
+	Value  string
+	Origin Origin
+}
+
+// layeredConfig is the servers merged from the config files, each of which
+// overrides the settings of the files with lower precedence
+type layeredConfig struct {
+	// files are the loaded config files by increasing precedence, each after
+	// the files it includes
+	files []*configFile
+	// layers are the loaded config files by decreasing precedence
+	layers   []Layer
+	servers  map[string]ServerConfig
+	patterns []string
+	settings map[string][]*Setting
+	// groups are replaced as a whole by the files with higher precedence
+	groups     map[string]*Group
+	groupNames []string
+	// contexts are replaced as a whole by the files with higher precedence
+	contexts       map[string]*Context
+	contextNames   []string
+	currentContext *Context
+	// aliases are replaced as a whole by the files with higher precedence
+	aliases    map[string]*Alias
+	aliasNames []string
+}
+
+// loadLayers loads the config files, given by decreasing precedence, and
+// merges them. The files included by a config file have a lower precedence
+// than it.
+func loadLayers(layers []Layer) (*layeredConfig, []error) {
+	l := &layeredConfig{
+		servers:  make(map[string]ServerConfig),
+		settings: make(map[string][]*Setting),
+		groups:   make(map[string]*Group),
+		contexts: make(map[string]*Context),
+		aliases:  make(map[string]*Alias),
+	}
+	loaded := make(map[string]bool)
+	including := make(map[string]bool)
+	var errs []error
+	var load func(layer Layer)
+	load = func(layer Layer) {
+		key, err := filepath.Abs(layer.File)
+		if err != nil {
+			key = layer.File
+		}
+		if loaded[key] {
+			return
+		}
+		loaded[key] = true
+		f, fileErrs := loadConfigFile(layer.File)
+		errs = append(errs, fileErrs...)
+		if f == nil {
+			return
+		}
+		including[key] = true
+		for _, inc := range f.includes {
+			incKey, _ := filepath.Abs(inc.path)
+			if including[incKey] {
+				errs = append(errs, f.errorAt(inc.node, "include cycle through %v", inc.path))
+				continue
+			}
+			load(Layer{inc.path, "included by " + f.path})
+		}
+		delete(including, key)
+		l.files = append(l.files, f)
+		l.layers = append([]Layer{layer}, l.layers...)
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		load(layers[i])
+	}
+	for _, f := range l.files {
+		l.merge(f)
+		l.mergeGroups(f)
+		l.mergeContexts(f)
+		l.mergeAliases(f)
+	}
+	errs = append(errs, l.checkGroups()...)
+	errs = append(errs, l.checkContexts()...)
+	errs = append(errs, l.checkAliases()...)
+	return l, errs
+}
+
+// merge overrides the settings of the servers with the ones of the file
+func (l *layeredConfig) merge(f *configFile) {
+	for _, pattern := range f.patterns {
+		src, ok := f.servers[pattern]
+		if !ok {
+			continue
+		}
+		dst, ok := l.servers[pattern]
+		if !ok {
+			l.patterns = append(l.patterns, pattern)
+		}
+		for _, leaf := range leafSettings(f.nodes[pattern], reflect.TypeOf(ServerConfig{}), nil) {
+			copySetting(reflect.ValueOf(&dst).Elem(), reflect.ValueOf(src), leaf.keys)
+			l.setSetting(pattern, &Setting{
+				Name:   strings.Join(leaf.keys, "."),
+				Value:  leaf.node.Value,
+				Origin: Origin{f.path, leaf.node.Line},
+			})
+		}
+		l.servers[pattern] = dst
+	}
+}
+
+// setSetting records the origin of a setting, keeping the position of an
+// overridden one
+func (l *layeredConfig) setSetting(pattern string, setting *Setting) {
+	for i, s := range l.settings[pattern] {
+		if s.Name == setting.Name {
+			l.settings[pattern][i] = setting
+			return
+		}
+	}
+	l.settings[pattern] = append(l.settings[pattern], setting)
+}
+
+type leafSetting struct {
+	keys []string
+	node *yaml.Node
+}
+
+// leafSettings returns the scalar settings of the mapping node, whose type is
+// t, e.g. "ssh_jump.host" and "headers.x-api-key"
+func leafSettings(node *yaml.Node, t reflect.Type, prefix []string) []leafSetting {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var fields map[string]reflect.Type
+	if t.Kind() == reflect.Struct {
+		fields = yamlFields(t)
+	}
+
+	var leaves []leafSetting
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		keys := append(append([]string(nil), prefix...), key)
+		var fieldType reflect.Type
+		switch t.Kind() {
+		case reflect.Struct:
+			var ok bool
+			if fieldType, ok = fields[key]; !ok {
+				// Reported while loading
+				continue
+			}
+		case reflect.Map:
+			fieldType = t.Elem()
+		default:
+			continue
+		}
+		if value.Kind == yaml.MappingNode {
+			leaves = append(leaves, leafSettings(value, fieldType, keys)...)
+		} else {
+			leaves = append(leaves, leafSetting{keys, value})
+		}
+	}
+	return leaves
+}
+
+// copySetting copies the setting of src at the given keys to dst
+func copySetting(dst, src reflect.Value, keys []string) {
+	if len(keys) == 0 {
+		dst.Set(src)
+		return
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		copySetting(dst.Elem(), src.Elem(), keys)
+	case reflect.Struct:
+		for i := 0; i < dst.NumField(); i++ {
+			if strings.Split(dst.Type().Field(i).Tag.Get("yaml"), ",")[0] == keys[0] {
+				copySetting(dst.Field(i), src.Field(i), keys[1:])
+				return
+			}
+		}
+	case reflect.Map:
+		key := reflect.ValueOf(keys[0])
+		if !src.MapIndex(key).IsValid() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		dst.SetMapIndex(key, src.MapIndex(key))
+	}
+}
+
+// defaults returns the defaults of the flags of the server, named by their
+// flags
+func (l *layeredConfig) defaults(pattern string) []*Setting {
+	var defaults []*Setting
+	for _, s := range l.settings[pattern] {
+		if name := strings.TrimPrefix(s.Name, "defaults."); name != s.Name {
+			defaults = append(defaults, &Setting{Name: name, Value: s.Value, Origin: s.Origin})
+		}
+	}
+	return defaults
+}
+
+// loadConfig loads and merges the config files in effect
+func loadConfig() (*layeredConfig, error) {
+	l, errs := loadLayers(baseLayers())
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid grpcdebug config:\n%v", errors.Join(errs...))
+	}
+	return l, nil
+}
+
+// Config is the config files in effect, loaded and merged once for all the
+// targets of a command
+type Config struct {
+	l *layeredConfig
+}
+
+// Load loads and merges the config files in effect
+func Load() (*Config, error) {
+	l, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range l.files {
+		verbose.Debugf("Loaded grpcdebug config from %v", f.path)
+	}
+	return &Config{l}, nil
+}
+
+// Layers returns the config files merged into the config, by decreasing
+// precedence, including the included ones. They are returned even if the
+// config is invalid.
+func Layers() ([]Layer, error) {
+	l, errs := loadLayers(baseLayers())
+	if len(errs) > 0 {
+		return l.layers, fmt.Errorf("invalid grpcdebug config:\n%v", errors.Join(errs...))
+	}
+	return l.layers, nil
+}
+
+// Servers returns the patterns of the merged servers, in the order they are
+// first defined
+func Servers() ([]string, error) {
+	l, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return l.patterns, nil
+}
+
+// ServerSettings returns the effective settings of the server, merged from
+// all config files, and where each of them is defined. If setting is not
+// empty, only it, or the settings nested in it, are returned.
+func ServerSettings(pattern, setting string) ([]*Setting, error) {
+	l, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := l.servers[pattern]; !ok {
+		return nil, fmt.Errorf("no server %q in the config", pattern)
+	}
+	if setting == "" {
+		return l.settings[pattern], nil
+	}
+	if _, err := settingType(setting); err != nil {
+		return nil, err
+	}
+	var settings []*Setting
+	for _, s := range l.settings[pattern] {
+		if s.Name == setting || strings.HasPrefix(s.Name, setting+".") {
+			settings = append(settings, s)
+		}
+	}
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("setting %q of server %q is not set", setting, pattern)
+	}
+	return settings, nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// chdir changes the working directory for the duration of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoadLayersOverride(t *testing.T) {
+	dir := t.TempDir()
+	low := writeFile(t, dir, "low.yaml", `servers:
+  prod:
+    real_address: low:50051
+    security: tls
+    credential_file: low.pem
+    headers:
+      a: low
+      b: low
+`)
+	high := writeFile(t, dir, "high.yaml", `include: included.yaml
+servers:
+  prod:
+    real_address: high:50051
+    headers:
+      a: high
+`)
+	included := writeFile(t, dir, "included.yaml", `servers:
+  prod:
+    real_address: included:50051
+    credential_file: included.pem
+`)
+	l, errs := loadLayers([]Layer{{high, "high"}, {low, "low"}})
+	if len(errs) > 0 {
+		t.Fatalf("loadLayers() failed: %q", errorStrings(errs))
+	}
+	want := ServerConfig{
+		RealAddress:    "high:50051",
+		Security:       TypeTLS,
+		CredentialFile: "included.pem",
+		Headers:        map[string]string{"a": "high", "b": "low"},
+	}
+	if got := l.servers["prod"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged server = %+v, want %+v", got, want)
+	}
+	origins := make(map[string]Origin)
+	for _, s := range l.settings["prod"] {
+		origins[s.Name] = s.Origin
+	}
+	wantOrigins := map[string]Origin{
+		"real_address":    {high, 4},
+		"security":        {low, 4},
+		"credential_file": {included, 4},
+		"headers.a":       {high, 6},
+		"headers.b":       {low, 8},
+	}
+	if !reflect.DeepEqual(origins, wantOrigins) {
+		t.Errorf("origins = %v, want %v", origins, wantOrigins)
+	}
+	var files []string
+	for _, layer := range l.layers {
+		files = append(files, layer.File)
+	}
+	// The included file has a lower precedence than the file including it
+	if wantFiles := []string{high, included, low}; !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("layers = %v, want %v", files, wantFiles)
+	}
+}
+
+func TestLoadLayersRelativeIncludes(t *testing.T) {
+	dir := t.TempDir()
+	main := writeFile(t, dir, "config/grpcdebug_config.yaml", "include:\n  - ../shared/teams.yaml\n")
+	writeFile(t, dir, "shared/teams.yaml", "include: nested/prod.yaml\n")
+	writeFile(t, dir, "shared/nested/prod.yaml", "servers:\n  prod:\n    real_address: prod:50051\n")
+	// Relative to the including files, not to the working directory
+	chdir(t, t.TempDir())
+	l, errs := loadLayers([]Layer{{main, "main"}})
+	if len(errs) > 0 {
+		t.Fatalf("loadLayers() failed: %q", errorStrings(errs))
+	}
+	if got := l.servers["prod"].RealAddress; got != "prod:50051" {
+		t.Errorf("real_address = %q, want prod:50051", got)
+	}
+	if got := len(l.layers); got != 3 {
+		t.Errorf("loaded %v layers, want 3", got)
+	}
+}
+
+func TestLoadLayersIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.yaml", "include: b.yaml\nservers:\n  a:\n")
+	b := writeFile(t, dir, "b.yaml", "include: a.yaml\nservers:\n  b:\n")
+	l, errs := loadLayers([]Layer{{a, "a"}})
+	want := []string{b + ":1:10: include cycle through " + a}
+	if got := errorStrings(errs); !reflect.DeepEqual(got, want) {
+		t.Errorf("loadLayers() = %q, want %q", got, want)
+	}
+	// The servers of both files are still loaded
+	if !reflect.DeepEqual(l.patterns, []string{"b", "a"}) {
+		t.Errorf("patterns = %v, want [b a]", l.patterns)
+	}
+}
+
+func TestLoadLayersIncludedOnce(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.yaml", "include: [shared.yaml, b.yaml]\n")
+	writeFile(t, dir, "b.yaml", "include: shared.yaml\n")
+	writeFile(t, dir, "shared.yaml", "servers:\n  shared:\n")
+	l, errs := loadLayers([]Layer{{a, "a"}})
+	if len(errs) > 0 {
+		t.Fatalf("loadLayers() failed: %q", errorStrings(errs))
+	}
+	if got := len(l.layers); got != 3 {
+		t.Errorf("loaded %v layers, want 3, the shared file once", got)
+	}
+}
+
+func TestLoadReadsFilesOnce(t *testing.T) {
+	file := useConfig(t, "servers:\n  prod:\n    real_address: prod:50051\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("failed to remove config: %v", err)
+	}
+	c, err := cfg.ServerConfig("prod")
+	if err != nil {
+		t.Fatalf("ServerConfig() failed: %v", err)
+	}
+	if c.RealAddress != "prod:50051" {
+		t.Errorf("ServerConfig().RealAddress = %q, want prod:50051", c.RealAddress)
+	}
+}
+
+func TestBaseLayersPrecedence(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	user, err := UserConfigFile()
+	if err != nil {
+		t.Fatalf("UserConfigFile() failed: %v", err)
+	}
+	writeFile(t, filepath.Dir(user), filepath.Base(user), "servers:\n  prod:\n    real_address: user:50051\n    security: tls\n    credential_file: ca.pem\n")
+	wd := t.TempDir()
+	chdir(t, wd)
+	writeFile(t, wd, configFileName, "servers:\n  prod:\n    real_address: wd:50051\n    rpc_timeout: 1s\n")
+	env := writeFile(t, t.TempDir(), "env.yaml", "servers:\n  prod:\n    real_address: env:50051\n")
+	t.Setenv(grpcdebugServerConfigEnvName, env)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	c, err := cfg.ServerConfig("prod")
+	if err != nil {
+		t.Fatalf("ServerConfig() failed: %v", err)
+	}
+	if c.RealAddress != "env:50051" || c.RPCTimeout.String() != "1s" || c.Security != TypeTLS {
+		t.Errorf("ServerConfig() = %+v, want env:50051 with the timeout of the working directory and the security of the user", c)
+	}
+}
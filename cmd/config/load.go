@@ -0,0 +1,418 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a problem of a config file, located at the offending setting
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%v: %v", e.File, e.Msg)
+	case e.Column == 0:
+		return fmt.Sprintf("%v:%v: %v", e.File, e.Line, e.Msg)
+	default:
+		return fmt.Sprintf("%v:%v:%v: %v", e.File, e.Line, e.Column, e.Msg)
+	}
+}
+
+// configFile is a loaded config file, keeping the YAML nodes of the servers to
+// locate their problems
+type configFile struct {
+	path    string
+	servers map[string]ServerConfig
+	// patterns are the server patterns in the order of the file
+	patterns []string
+	nodes    map[string]*yaml.Node
+	// includes are the files included by this one, resolved against it
+	includes []include
+	groups   map[string]*Group
+	// groupNames are the names of the groups in the order of the file
+	groupNames []string
+	contexts   map[string]*Context
+	// contextNames are the names of the contexts in the order of the file
+	contextNames   []string
+	currentContext *Context
+	aliases        map[string]*Alias
+	// aliasNames are the names of the aliases in the order of the file
+	aliasNames []string
+}
+
+type include struct {
+	path string
+	node *yaml.Node
+}
+
+// yamlErrorPattern extracts the line of the errors of the YAML parser, e.g.
+// "yaml: line 3: did not find expected key"
+var yamlErrorPattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func (f *configFile) errorAt(node *yaml.Node, format string, a ...interface{}) error {
+	e := &Error{File: f.path, Msg: fmt.Sprintf(format, a...)}
+	if node != nil {
+		e.Line, e.Column = node.Line, node.Column
+	}
+	return e
+}
+
+// valueAt returns the first value node at the line under node, skipping the
+// keys of the mappings. A block mapping starts at its first key, so the value
+// of that key is returned instead.
+func valueAt(node *yaml.Node, line int) *yaml.Node {
+	if node.Line == line && node.Kind != yaml.MappingNode {
+		return node
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		if n := valueAt(child, line); n != nil {
+			return n
+		}
+	}
+	if node.Line == line {
+		return node
+	}
+	return nil
+}
+
+// yamlErrors converts the errors of the YAML package, which only know the
+// line, to Errors. The errors of decoding node are located at the column of
+// the offending value.
+func (f *configFile) yamlErrors(err error, node *yaml.Node) []error {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+	var errs []error
+	for _, message := range messages {
+		e := &Error{File: f.path, Msg: strings.TrimPrefix(message, "yaml: ")}
+		if m := yamlErrorPattern.FindStringSubmatch(message); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Msg = m[2]
+			if node != nil {
+				if value := valueAt(node, e.Line); value != nil {
+					e.Column = value.Column
+				}
+			}
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// loadConfigFile loads the config file, returning all problems found
+func loadConfigFile(file string) (*configFile, []error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to read config file: %v", err)}
+	}
+	return loadConfigData(file, data)
+}
+
+// loadConfigData loads the content of the config file
+func loadConfigData(file string, data []byte) (*configFile, []error) {
+	f := &configFile{
+		path:     file,
+		servers:  make(map[string]ServerConfig),
+		nodes:    make(map[string]*yaml.Node),
+		groups:   make(map[string]*Group),
+		contexts: make(map[string]*Context),
+		aliases:  make(map[string]*Alias),
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, f.yamlErrors(err, nil)
+	}
+	if len(root.Content) == 0 {
+		// Empty file
+		return f, nil
+	}
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		return nil, []error{f.errorAt(document, "expecting a mapping with a servers key")}
+	}
+	var errs []error
+	fields := yamlFields(reflect.TypeOf(grpcdebugConfig{}))
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		key, value := document.Content[i], document.Content[i+1]
+		if _, ok := fields[key.Value]; !ok {
+			errs = append(errs, f.errorAt(key, "unknown field %q", key.Value))
+			continue
+		}
+		switch key.Value {
+		case "servers":
+			errs = append(errs, f.loadServers(value)...)
+		case "include":
+			errs = append(errs, f.loadIncludes(value)...)
+		case "groups":
+			errs = append(errs, f.loadGroups(value)...)
+		case "contexts":
+			errs = append(errs, f.loadContexts(value)...)
+		case "current_context":
+			errs = append(errs, f.loadCurrentContext(value)...)
+		case "aliases":
+			errs = append(errs, f.loadAliases(value)...)
+		}
+	}
+	sortErrors(errs)
+	return f, errs
+}
+
+// sortErrors sorts the Errors by their files and positions
+func sortErrors(errs []error) {
+	position := func(err error) (string, int, int) {
+		if e, ok := err.(*Error); ok {
+			return e.File, e.Line, e.Column
+		}
+		return "", 0, 0
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		iFile, iLine, iColumn := position(errs[i])
+		jFile, jLine, jColumn := position(errs[j])
+		if iFile != jFile {
+			return iFile < jFile
+		}
+		if iLine != jLine {
+			return iLine < jLine
+		}
+		return iColumn < jColumn
+	})
+}
+
+// loadIncludes loads the include directive, which is a path or a list of paths
+func (f *configFile) loadIncludes(node *yaml.Node) []error {
+	nodes := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		nodes = node.Content
+	}
+	var errs []error
+	for _, n := range nodes {
+		if n.Kind != yaml.ScalarNode || n.Tag != "!!str" {
+			errs = append(errs, f.errorAt(n, "include must be a path or a list of paths"))
+			continue
+		}
+		file := n.Value
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(f.path), file)
+		}
+		f.includes = append(f.includes, include{path: file, node: n})
+	}
+	return errs
+}
+
+func (f *configFile) loadServers(node *yaml.Node) []error {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return []error{f.errorAt(node, "servers must be a mapping from patterns to server configs")}
+	}
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		pattern := key.Value
+		if _, ok := f.nodes[pattern]; ok {
+			errs = append(errs, f.errorAt(key, "duplicate server pattern %q, first defined at line %v", pattern, f.nodes[pattern].Line))
+			continue
+		}
+		f.nodes[pattern] = value
+		f.patterns = append(f.patterns, pattern)
+		if err := checkPattern(pattern); err != nil {
+			errs = append(errs, f.errorAt(key, "%v", err))
+		}
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+			f.servers[pattern] = ServerConfig{}
+			continue
+		}
+		if value.Kind != yaml.MappingNode {
+			errs = append(errs, f.errorAt(value, "server %q must be a mapping of settings", pattern))
+			continue
+		}
+		unknown := f.checkFields(value, reflect.TypeOf(ServerConfig{}), fmt.Sprintf("server %q", pattern))
+		errs = append(errs, unknown...)
+		var c ServerConfig
+		if err := value.Decode(&c); err != nil {
+			errs = append(errs, f.yamlErrors(err, value)...)
+			// The rest of the settings are still decoded after type errors
+			if _, ok := err.(*yaml.TypeError); !ok {
+				continue
+			}
+		}
+		errs = append(errs, f.checkServer(pattern, &c)...)
+		f.servers[pattern] = c
+	}
+	return errs
+}
+
+// checkFields reports the keys of the mapping without a field in t, which the
+// YAML package silently ignores. owner names the mapping in the errors, e.g.
+// `server "prod"`.
+func (f *configFile) checkFields(node *yaml.Node, t reflect.Type, owner string) []error {
+	fields := yamlFields(t)
+	var errs []error
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		fieldType, ok := fields[key.Value]
+		if !ok {
+			errs = append(errs, f.errorAt(key, "unknown setting %q of %v", key.Value, owner))
+			continue
+		}
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && value.Kind == yaml.MappingNode {
+			errs = append(errs, f.checkFields(value, fieldType, owner)...)
+		}
+	}
+	return errs
+}
+
+// yamlFields returns the types of the fields of the struct by their YAML keys
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "-" {
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// checkPattern verifies the glob or regex server pattern compiles
+func checkPattern(pattern string) error {
+	switch {
+	case strings.HasPrefix(pattern, regexPatternPrefix):
+		if _, err := regexp.Compile(strings.TrimPrefix(pattern, regexPatternPrefix)); err != nil {
+			return fmt.Errorf("invalid regex server pattern %q: %v", pattern, err)
+		}
+	case isGlobPattern(pattern):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob server pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// lookup returns the node of the setting, e.g. "ssh_jump.key_file", falling
+// back to the server config itself if the setting is absent
+func lookup(node *yaml.Node, setting string) *yaml.Node {
+	for _, key := range strings.Split(setting, ".") {
+		if node.Kind != yaml.MappingNode {
+			return node
+		}
+		found := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				node = node.Content[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return node
+		}
+	}
+	return node
+}
+
+// checkServer verifies the values of the settings of a server
+func (f *configFile) checkServer(pattern string, c *ServerConfig) []error {
+	node := f.nodes[pattern]
+	var errs []error
+	switch c.Security {
+	case "", TypeInsecure, TypeTLS:
+	default:
+		errs = append(errs, f.errorAt(lookup(node, "security"), "invalid security mode %q of server %q, expecting insecure or tls", c.Security, pattern))
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		errs = append(errs, f.errorAt(node, "server %q must set both client_cert_file and client_key_file", pattern))
+	}
+	if c.MaxReceiveSize != "" {
+		if _, err := humanize.ParseBytes(c.MaxReceiveSize); err != nil {
+			errs = append(errs, f.errorAt(lookup(node, "max_receive_size"), "invalid max_receive_size %q of server %q: %v", c.MaxReceiveSize, pattern, err))
+		}
+	}
+	if c.Compression != "" && c.Compression != "gzip" {
+		errs = append(errs, f.errorAt(lookup(node, "compression"), "unsupported compression %q of server %q, expecting gzip", c.Compression, pattern))
+	}
+	if c.SSHJump != nil && c.SSHJump.Host == "" {
+		errs = append(errs, f.errorAt(lookup(node, "ssh_jump"), "ssh_jump of server %q has no host", pattern))
+	}
+	settings := yamlFields(reflect.TypeOf(ServerConfig{}))
+	for name := range c.Defaults {
+		// The connection flags are applied before the defaults are known
+		flag := strings.ReplaceAll(name, "-", "_")
+		if _, ok := settings[flag]; ok || flag == "header" || flag == "target" {
+			errs = append(errs, f.errorAt(lookup(node, "defaults."+name), "default %q of server %q is a connection setting, please set it as a setting of the server", name, pattern))
+		}
+	}
+	return errs
+}
+
+// Checks verify the parts of the config which depend on the commands
+type Checks struct {
+	// Default verifies the name of a default flag of a server
+	Default func(name string) error
+	// Alias verifies the name and the command line of an alias
+	Alias func(name string, args []string) error
+}
+
+// Validate loads the config files in effect, or the given one, with the
+// files they include, and returns all problems found, including referenced
+// files which don't exist
+func Validate(file string, checks Checks) ([]Layer, []error) {
+	layers := baseLayers()
+	if file != "" {
+		layers = []Layer{{file, "from the command line"}}
+	}
+	if len(layers) == 0 {
+		return nil, nil
+	}
+	l, errs := loadLayers(layers)
+	for _, pattern := range l.patterns {
+		errs = append(errs, l.checkFiles(pattern)...)
+		for _, setting := range l.defaults(pattern) {
+			if err := checks.Default(setting.Name); err != nil {
+				errs = append(errs, &Error{File: setting.Origin.File, Line: setting.Origin.Line, Msg: fmt.Sprintf("default %q of server %q: %v", setting.Name, pattern, err)})
+			}
+		}
+	}
+	for _, name := range l.groupNames {
+		if _, err := l.expandGroup(name, make(map[string]bool)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range l.contextNames {
+		if _, _, err := l.contextTargets(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range l.aliasNames {
+		alias := l.aliases[name]
+		if err := checks.Alias(name, alias.Args()); err != nil {
+			errs = append(errs, &Error{File: alias.Origin.File, Line: alias.Origin.Line, Msg: fmt.Sprintf("alias %q: %v", name, err)})
+		}
+	}
+	sortErrors(errs)
+	return l.layers, errs
+}
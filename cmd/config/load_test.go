@@ -0,0 +1,116 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// errorStrings returns the messages of the errors
+func errorStrings(errs []error) []string {
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown top level field",
+			content: "servers:\nserver:\n",
+			want:    []string{`config.yaml:2:1: unknown field "server"`},
+		},
+		{
+			name: "unknown setting",
+			content: `servers:
+  localhost:50051:
+    real_adress: localhost:50052
+`,
+			want: []string{`config.yaml:3:5: unknown setting "real_adress" of server "localhost:50051"`},
+		},
+		{
+			name: "unknown nested setting",
+			content: `servers:
+  localhost:50051:
+    ssh_jump:
+      host: bastion
+      key: id_ed25519
+`,
+			want: []string{`config.yaml:5:7: unknown setting "key" of server "localhost:50051"`},
+		},
+		{
+			name: "type mismatch",
+			content: `servers:
+  localhost:50051:
+    connect_timeout: soon
+`,
+			want: []string{"config.yaml:3:22: cannot unmarshal !!str `soon` into time.Duration"},
+		},
+		{
+			name: "invalid value",
+			content: `servers:
+  localhost:50051:
+    security: plaintext
+`,
+			want: []string{`config.yaml:3:15: invalid security mode "plaintext" of server "localhost:50051", expecting insecure or tls`},
+		},
+		{
+			name: "errors sorted by position",
+			content: `servers:
+  localhost:50051:
+    compression: zstd
+    tls: true
+`,
+			want: []string{
+				`config.yaml:3:18: unsupported compression "zstd" of server "localhost:50051", expecting gzip`,
+				`config.yaml:4:5: unknown setting "tls" of server "localhost:50051"`,
+			},
+		},
+		{
+			name: "type mismatch of a nested setting",
+			content: `servers:
+  localhost:50051:
+    headers:
+      - x-api-key
+`,
+			want: []string{"config.yaml:4:7: cannot unmarshal !!seq into map[string]string"},
+		},
+		{
+			name:    "syntax error",
+			content: "servers:\n  localhost:50051: [\n",
+			want:    []string{"config.yaml:2: did not find expected node content"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := filepath.Join("testdata", "config.yaml")
+			_, errs := loadConfigData(file, []byte(test.content))
+			want := make([]string, len(test.want))
+			for i, w := range test.want {
+				want[i] = filepath.Join("testdata", w)
+			}
+			if got := errorStrings(errs); !reflect.DeepEqual(got, want) {
+				t.Errorf("loadConfigData() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTypeMismatchKeepsOtherSettings(t *testing.T) {
+	f, errs := loadConfigData("config.yaml", []byte(`servers:
+  localhost:50051:
+    real_address: localhost:50052
+    connect_timeout: soon
+`))
+	if len(errs) != 1 {
+		t.Fatalf("loadConfigData() = %q, want one error", errorStrings(errs))
+	}
+	if got := f.servers["localhost:50051"].RealAddress; got != "localhost:50052" {
+		t.Errorf("real_address = %q, want localhost:50052", got)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// envReferencePattern matches the references to environment variables, e.g.
+// ${HOME}, which are interpolated into the settings
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+const (
+	// envSecretPrefix marks a header value read from an environment variable,
+	// e.g. "env:API_TOKEN"
+	envSecretPrefix = "env:"
+	// fileSecretPrefix marks a header value read from a file, e.g.
+	// "file:./token.txt"
+	fileSecretPrefix = "file:"
+)
+
+// stringSetting is a setting of a server config referencing environment
+// variables
+type stringSetting struct {
+	key   string
+	value *string
+	// path is set if the setting is the path of a file or a directory, which
+	// is resolved against the config file defining it
+	path bool
+}
+
+func stringSettings(c *ServerConfig) []stringSetting {
+	settings := []stringSetting{
+		{"real_address", &c.RealAddress, false},
+		{"credential_file", &c.CredentialFile, true},
+		{"server_name_override", &c.ServerNameOverride, false},
+		{"ca_dir", &c.CADir, true},
+		{"client_cert_file", &c.ClientCertFile, true},
+		{"client_key_file", &c.ClientKeyFile, true},
+		{"token_file", &c.TokenFile, true},
+		{"credential_helper", &c.CredentialHelper, false},
+		{"proxy", &c.Proxy, false},
+		{"xds_bootstrap", &c.XdsBootstrap, true},
+	}
+	if c.SSHJump != nil {
+		settings = append(settings,
+			stringSetting{"ssh_jump.host", &c.SSHJump.Host, false},
+			stringSetting{"ssh_jump.user", &c.SSHJump.User, false},
+			stringSetting{"ssh_jump.key_file", &c.SSHJump.KeyFile, true},
+			stringSetting{"ssh_jump.known_hosts", &c.SSHJump.KnownHosts, true},
+		)
+	}
+	return settings
+}
+
+// origin returns where the setting of the server is defined
+func (l *layeredConfig) origin(pattern, setting string) Origin {
+	for _, s := range l.settings[pattern] {
+		if s.Name == setting {
+			return s.Origin
+		}
+	}
+	return Origin{}
+}
+
+func (l *layeredConfig) errorAt(pattern, setting string, format string, a ...interface{}) error {
+	origin := l.origin(pattern, setting)
+	return &Error{File: origin.File, Line: origin.Line, Msg: fmt.Sprintf(format, a...)}
+}
+
+// interpolate replaces the references to environment variables in the
+// setting. The capture groups of regex patterns, e.g. ${host}, are left to be
+// substituted after matching the target.
+func (l *layeredConfig) interpolate(pattern, setting, value string, groups map[string]bool) (string, []error) {
+	var errs []error
+	value = envReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReferencePattern.FindStringSubmatch(reference)[1]
+		if groups[name] {
+			return reference
+		}
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			errs = append(errs, l.errorAt(pattern, setting, "environment variable %v referenced by %v of server %q is not set", name, setting, pattern))
+		}
+		return env
+	})
+	return value, errs
+}
+
+// portPattern matches the port of an address, e.g. "env:50051" is the address
+// of a host named env rather than a reference
+var portPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// isSecretReference reports whether the value is read from an environment
+// variable or a file, which only header values support
+func isSecretReference(value string) bool {
+	for _, prefix := range []string{envSecretPrefix, fileSecretPrefix} {
+		if strings.HasPrefix(value, prefix) && !portPattern.MatchString(strings.TrimPrefix(value, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecret reads the header value referencing an environment variable
+// or a file
+func (l *layeredConfig) resolveSecret(pattern, setting, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, envSecretPrefix):
+		name := strings.TrimPrefix(value, envSecretPrefix)
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			return "", l.errorAt(pattern, setting, "environment variable %v referenced by %v of server %q is not set", name, setting, pattern)
+		}
+		return env, nil
+	case strings.HasPrefix(value, fileSecretPrefix):
+		file := strings.TrimPrefix(value, fileSecretPrefix)
+		if origin := l.origin(pattern, setting); !filepath.IsAbs(file) && origin.File != "" {
+			file = filepath.Join(filepath.Dir(origin.File), file)
+		}
+		secret, err := os.ReadFile(file)
+		if err != nil {
+			return "", l.errorAt(pattern, setting, "failed to read %v of server %q: %v", setting, pattern, err)
+		}
+		return strings.TrimRight(string(secret), "\r\n"), nil
+	}
+	return value, nil
+}
+
+// resolve returns the server config of the pattern, with the references to
+// environment variables and secrets replaced, and the relative paths resolved
+// against the config files defining them
+func (l *layeredConfig) resolve(pattern string) (ServerConfig, []error) {
+	c := l.servers[pattern]
+	groups := make(map[string]bool)
+	if strings.HasPrefix(pattern, regexPatternPrefix) {
+		for _, name := range regexp.MustCompile(strings.TrimPrefix(pattern, regexPatternPrefix)).SubexpNames() {
+			groups[name] = name != ""
+		}
+	}
+	var errs []error
+	if c.SSHJump != nil {
+		jump := *c.SSHJump
+		c.SSHJump = &jump
+	}
+	for _, setting := range stringSettings(&c) {
+		if *setting.value == "" {
+			continue
+		}
+		if isSecretReference(*setting.value) {
+			// Would be taken literally, e.g. dialing "env:PROD_HOST"
+			errs = append(errs, l.errorAt(pattern, setting.key, "%v of server %q can't be read from %q, only header values can, please reference environment variables as ${VAR}", setting.key, pattern, *setting.value))
+			continue
+		}
+		value, interpolateErrs := l.interpolate(pattern, setting.key, *setting.value, groups)
+		errs = append(errs, interpolateErrs...)
+		if origin := l.origin(pattern, setting.key); setting.path && !filepath.IsAbs(value) && origin.File != "" {
+			value = filepath.Join(filepath.Dir(origin.File), value)
+		}
+		*setting.value = value
+	}
+	if c.Headers != nil {
+		headers := make(map[string]string, len(c.Headers))
+		for key, value := range c.Headers {
+			setting := "headers." + key
+			value, interpolateErrs := l.interpolate(pattern, setting, value, groups)
+			errs = append(errs, interpolateErrs...)
+			secret, err := l.resolveSecret(pattern, setting, value)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			headers[key] = secret
+		}
+		c.Headers = headers
+	}
+	sortErrors(errs)
+	return c, errs
+}
+
+// checkFiles verifies the references of the server config can be resolved,
+// and the files it references exist
+func (l *layeredConfig) checkFiles(pattern string) []error {
+	c, errs := l.resolve(pattern)
+	if len(errs) > 0 {
+		return errs
+	}
+	for _, setting := range stringSettings(&c) {
+		if !setting.path || *setting.value == "" {
+			continue
+		}
+		if _, err := os.Stat(*setting.value); err != nil {
+			errs = append(errs, l.errorAt(pattern, setting.key, "%v of server %q: %v", setting.key, pattern, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	t.Setenv("PROD_HOST", "prod.internal")
+	t.Setenv("CERTS_DIR", "certs")
+	t.Setenv("PROD_API_KEY", "secret-key")
+	file := useConfig(t, `servers:
+  prod:
+    real_address: "${PROD_HOST}:50051"
+    security: tls
+    credential_file: ${CERTS_DIR}/prod_ca.pem
+    headers:
+      authorization: file:./prod_token.txt
+      x-api-key: env:PROD_API_KEY
+      x-host: ${PROD_HOST}
+`)
+	writeFile(t, filepath.Dir(file), "prod_token.txt", "Bearer token\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	c, err := cfg.ServerConfig("prod")
+	if err != nil {
+		t.Fatalf("ServerConfig() failed: %v", err)
+	}
+	want := ServerConfig{
+		RealAddress: "prod.internal:50051",
+		Security:    TypeTLS,
+		// Relative to the config file
+		CredentialFile: filepath.Join(filepath.Dir(file), "certs", "prod_ca.pem"),
+		Headers: map[string]string{
+			"authorization": "Bearer token",
+			"x-api-key":     "secret-key",
+			"x-host":        "prod.internal",
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("ServerConfig() = %+v, want %+v", c, want)
+	}
+}
+
+func TestResolveHostNamedEnv(t *testing.T) {
+	useConfig(t, "servers:\n  prod:\n    real_address: env:50051\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	c, err := cfg.ServerConfig("prod")
+	if err != nil {
+		t.Fatalf("ServerConfig() failed: %v", err)
+	}
+	if c.RealAddress != "env:50051" {
+		t.Errorf("ServerConfig().RealAddress = %q, want env:50051", c.RealAddress)
+	}
+}
+
+func TestResolveCaptureGroups(t *testing.T) {
+	t.Setenv("DOMAIN", "internal")
+	useConfig(t, `servers:
+  "regex:^(?P<host>\\w+)\\.prod$":
+    real_address: "${host}.${DOMAIN}:50051"
+`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	c, err := cfg.ServerConfig("a.prod")
+	if err != nil {
+		t.Fatalf("ServerConfig() failed: %v", err)
+	}
+	if c.RealAddress != "a.internal:50051" {
+		t.Errorf("ServerConfig().RealAddress = %q, want a.internal:50051", c.RealAddress)
+	}
+}
+
+func TestResolveReferenceErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing variable in real_address",
+			content: "servers:\n  prod:\n    real_address: \"${MISSING_HOST}:50051\"\n",
+			want:    `:3: environment variable MISSING_HOST referenced by real_address of server "prod" is not set`,
+		},
+		{
+			name:    "missing variable in credential_file",
+			content: "servers:\n  prod:\n    security: tls\n    credential_file: ${MISSING_DIR}/ca.pem\n",
+			want:    `:4: environment variable MISSING_DIR referenced by credential_file of server "prod" is not set`,
+		},
+		{
+			name:    "missing variable of a header",
+			content: "servers:\n  prod:\n    headers:\n      x-api-key: env:MISSING_KEY\n",
+			want:    `:4: environment variable MISSING_KEY referenced by headers.x-api-key of server "prod" is not set`,
+		},
+		{
+			name:    "missing file of a header",
+			content: "servers:\n  prod:\n    headers:\n      authorization: file:missing.txt\n",
+			want:    `:4: failed to read headers.authorization of server "prod": open `,
+		},
+		{
+			name:    "env reference in real_address",
+			content: "servers:\n  prod:\n    real_address: env:PROD_HOST\n",
+			want:    `:3: real_address of server "prod" can't be read from "env:PROD_HOST", only header values can`,
+		},
+		{
+			name:    "file reference in credential_file",
+			content: "servers:\n  prod:\n    security: tls\n    credential_file: file:ca_path.txt\n",
+			want:    `:4: credential_file of server "prod" can't be read from "file:ca_path.txt", only header values can`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := useConfig(t, test.content)
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			_, err = cfg.ServerConfig("prod")
+			if err == nil || !strings.Contains(err.Error(), file+test.want) {
+				t.Errorf("ServerConfig() = %v, want error containing %q", err, file+test.want)
+			}
+			// Reported by config validate too
+			_, errs := Validate("", Checks{})
+			if got := strings.Join(errorStrings(errs), "\n"); !strings.Contains(got, file+test.want) {
+				t.Errorf("Validate() = %q, want error containing %q", got, file+test.want)
+			}
+		})
+	}
+}
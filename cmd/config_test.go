@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+)
+
+const commentedConfig = `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.internal:50051 # behind the load balancer
+    security: tls
+    credential_file: ca.pem
+  localhost:50051:
+`
+
+// configContent returns the content of the config file used by the last
+// command
+func configContent(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(os.Getenv("GRPCDEBUG_CONFIG"))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	return string(data)
+}
+
+func TestConfigSet(t *testing.T) {
+	result := runCommandWithConfig(t, commentedConfig, nil, "config", "set", "prod", "ssh_jump.host", "bastion")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config set failed: %v", result.err)
+	}
+	file := os.Getenv("GRPCDEBUG_CONFIG")
+	if want := `Set ssh_jump.host of server "prod" in ` + file + "\n"; result.stdout != want {
+		t.Errorf("grpcdebug config set printed %q, want %q", result.stdout, want)
+	}
+	want := `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.internal:50051 # behind the load balancer
+    security: tls
+    credential_file: ca.pem
+    ssh_jump:
+      host: bastion
+  localhost:50051:
+`
+	if got := configContent(t); got != want {
+		t.Errorf("config after grpcdebug config set:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestConfigSetInvalid(t *testing.T) {
+	result := runCommandWithConfig(t, commentedConfig, nil, "config", "set", "prod", "compression", "zstd")
+	if result.err == nil || !strings.Contains(result.err.Error(), `unsupported compression "zstd"`) {
+		t.Errorf("grpcdebug config set = %v, want error of the unsupported compression", result.err)
+	}
+	if got := configContent(t); got != commentedConfig {
+		t.Errorf("config after failed grpcdebug config set:\n%v\nwant it unchanged", got)
+	}
+}
+
+func TestConfigDelete(t *testing.T) {
+	result := runCommandWithConfig(t, commentedConfig, nil, "config", "delete", "localhost:50051")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config delete failed: %v", result.err)
+	}
+	file := os.Getenv("GRPCDEBUG_CONFIG")
+	if want := `Deleted server "localhost:50051" in ` + file + "\n"; result.stdout != want {
+		t.Errorf("grpcdebug config delete printed %q, want %q", result.stdout, want)
+	}
+	want := `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.internal:50051 # behind the load balancer
+    security: tls
+    credential_file: ca.pem
+`
+	if got := configContent(t); got != want {
+		t.Errorf("config after grpcdebug config delete:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestConfigDeleteSetting(t *testing.T) {
+	result := runCommandWithConfig(t, commentedConfig, nil, "config", "delete", "prod", "security")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config delete failed: %v", result.err)
+	}
+	want := `# Shared servers of the team
+servers:
+  # The production fleet
+  prod:
+    real_address: prod.internal:50051 # behind the load balancer
+    credential_file: ca.pem
+  localhost:50051:
+`
+	if got := configContent(t); got != want {
+		t.Errorf("config after grpcdebug config delete:\n%v\nwant:\n%v", got, want)
+	}
+	result = runCommandWithConfig(t, commentedConfig, nil, "config", "delete", "prod", "proxy")
+	if result.err == nil || result.err.Error() != `setting "proxy" of server "prod" is not set` {
+		t.Errorf("grpcdebug config delete of an unset setting = %v, want error", result.err)
+	}
+}
+
+const contextsConfig = `servers:
+  prod:50051:
+contexts:
+  prod:
+    target: prod:50051
+  staging:
+    target: staging:50051
+current_context: prod
+`
+
+func TestResolveTarget(t *testing.T) {
+	defer func() { targetFlag = "" }()
+	for _, test := range []struct {
+		name    string
+		config  string
+		flag    string
+		env     string
+		want    string
+		wantErr string
+	}{
+		{name: "flag over all", config: contextsConfig, flag: "flag:50051", env: "env:50051", want: "flag:50051"},
+		{name: "environment over context", config: contextsConfig, env: "env:50051", want: "env:50051"},
+		{name: "current context", config: contextsConfig, want: "prod:50051"},
+		{name: "none", config: "servers:\n  prod:50051:\n", wantErr: `no target specified, please set one with --target, $GRPCDEBUG_TARGET or "grpcdebug config use-context"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			writeConfig(t, test.config)
+			t.Setenv(targetEnv, test.env)
+			targetFlag = test.flag
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			got, err := resolveTarget(cfg)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("resolveTarget() = %q, %v, want error %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("resolveTarget() = %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestCurrentContextTarget(t *testing.T) {
+	backends := map[string]*fakeBackend{
+		"prod:50051": {health: map[string]string{"": "SERVING"}},
+	}
+	result := runCommandWithConfig(t, contextsConfig, backends, "health", "-o", "csv")
+	if result.err != nil {
+		t.Fatalf("grpcdebug health failed: %v", result.err)
+	}
+	if len(result.connected) != 1 || result.connected[0].RealAddress != "prod:50051" {
+		t.Errorf("grpcdebug health connected to %v, want the target of the current context", result.connected)
+	}
+}
+
+func TestConfigUseContext(t *testing.T) {
+	result := runCommandWithConfig(t, contextsConfig, nil, "config", "use-context", "staging")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config use-context failed: %v", result.err)
+	}
+	file := os.Getenv("GRPCDEBUG_CONFIG")
+	if want := `Switched to context "staging" in ` + file + "\n"; result.stdout != want {
+		t.Errorf("grpcdebug config use-context printed %q, want %q", result.stdout, want)
+	}
+	if got := configContent(t); !strings.HasSuffix(got, "current_context: staging\n") {
+		t.Errorf("config after grpcdebug config use-context:\n%v\nwant the current context staging", got)
+	}
+
+	result = runCommandWithConfig(t, contextsConfig, nil, "config", "use-context", "dev")
+	if result.err == nil || !strings.Contains(result.err.Error(), `no context "dev"`) {
+		t.Errorf("grpcdebug config use-context dev = %v, want error of the missing context", result.err)
+	}
+	if got := configContent(t); got != contextsConfig {
+		t.Errorf("config after failed grpcdebug config use-context:\n%v\nwant it unchanged", got)
+	}
+}
+
+func TestConfigSetContext(t *testing.T) {
+	result := runCommandWithConfig(t, contextsConfig, nil, "config", "set-context", "dev", "localhost:50051,localhost:50052")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config set-context failed: %v", result.err)
+	}
+	want := `servers:
+  prod:50051:
+contexts:
+  prod:
+    target: prod:50051
+  staging:
+    target: staging:50051
+  dev:
+    target: localhost:50051,localhost:50052
+current_context: prod
+`
+	if got := configContent(t); got != want {
+		t.Errorf("config after grpcdebug config set-context:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestConfigDeleteContext(t *testing.T) {
+	result := runCommandWithConfig(t, contextsConfig, nil, "config", "delete-context", "prod")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config delete-context failed: %v", result.err)
+	}
+	// The current context is unset along
+	want := `servers:
+  prod:50051:
+contexts:
+  staging:
+    target: staging:50051
+`
+	if got := configContent(t); got != want {
+		t.Errorf("config after grpcdebug config delete-context:\n%v\nwant:\n%v", got, want)
+	}
+	result = runCommandWithConfig(t, contextsConfig, nil, "config", "delete-context", "dev")
+	if result.err == nil || !strings.Contains(result.err.Error(), `no context "dev"`) {
+		t.Errorf("grpcdebug config delete-context dev = %v, want error of the missing context", result.err)
+	}
+}
+
+func TestConfigCurrentContext(t *testing.T) {
+	result := runCommandWithConfig(t, contextsConfig, nil, "config", "current-context", "-o", "json")
+	if result.err != nil {
+		t.Fatalf("grpcdebug config current-context failed: %v", result.err)
+	}
+	if !strings.Contains(result.stdout, `"name": "prod"`) || !strings.Contains(result.stdout, `"target": "prod:50051"`) {
+		t.Errorf("grpcdebug config current-context printed:\n%v\nwant the context prod", result.stdout)
+	}
+	result = runCommandWithConfig(t, "servers:\n", nil, "config", "current-context")
+	if result.err == nil || result.err.Error() != "no current context" {
+		t.Errorf("grpcdebug config current-context = %v, want error of no current context", result.err)
+	}
+}
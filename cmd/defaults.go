@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagDefault is the default of a flag in the server config of a target
+type flagDefault struct {
+	value   string
+	pattern string
+	origin  config.Origin
+}
+
+func (d flagDefault) String() string {
+	return fmt.Sprintf("the defaults of server %q (%v)", d.pattern, d.origin)
+}
+
+// applyDefaults sets the flags of the command which aren't given on the
+// command line to the defaults of the server configs of the targets. The
+// targets must agree on the defaults they share.
+func applyDefaults(cmd *cobra.Command, cfg *config.Config, addresses []string) error {
+	defaults := make(map[string]flagDefault)
+	var names []string
+	for _, address := range addresses {
+		pattern, settings := cfg.ServerDefaults(address)
+		for _, s := range settings {
+			d := flagDefault{s.Value, pattern, s.Origin}
+			if other, ok := defaults[s.Name]; ok {
+				if other.value != d.value {
+					return fmt.Errorf("the targets have different defaults of --%v, from %v and %v, please give it on the command line", s.Name, other, d)
+				}
+				continue
+			}
+			defaults[s.Name] = d
+			names = append(names, s.Name)
+		}
+	}
+	applied := make(map[string]flagDefault)
+	for _, name := range names {
+		d := defaults[name]
+		flag := cmd.Flags().Lookup(name)
+		switch {
+		case flag == nil:
+			verbose.Debugf("Ignoring --%v from %v, which %q doesn't have", name, d, cmd.CommandPath())
+		case flag.Changed:
+		default:
+			if err := cmd.Flags().Set(name, d.value); err != nil {
+				return fmt.Errorf("invalid default --%v=%v from %v: %v", name, d.value, d, err)
+			}
+			applied[flag.Name] = d
+		}
+	}
+	// A default may enable the verbose mode
+	initConfig(cmd)
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" {
+			return
+		}
+		source := "its built-in default"
+		if d, ok := applied[flag.Name]; ok {
+			source = d.String()
+		} else if flag.Changed {
+			source = "the command line"
+		}
+		verbose.Debugf("Flag --%v=%v from %v", flag.Name, redactedFlagValue(flag), source)
+	})
+	return nil
+}
+
+// redactedFlagValue formats the value of the flag with its secrets redacted,
+// see config.RedactSetting
+func redactedFlagValue(flag *pflag.Flag) string {
+	slice, ok := flag.Value.(pflag.SliceValue)
+	if !ok {
+		return config.RedactSetting(flag.Name, flag.Value.String())
+	}
+	var values []string
+	for _, value := range slice.GetSlice() {
+		values = append(values, config.RedactSetting(flag.Name, value))
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+// checkDefaultFlag verifies a default of the server configs is a flag of any
+// command
+func checkDefaultFlag(name string) error {
+	var found bool
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		if cmd.Flags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+			found = true
+		}
+		for _, child := range cmd.Commands() {
+			visit(child)
+		}
+	}
+	visit(rootCmd)
+	if !found {
+		return fmt.Errorf("no command has the flag --%v", name)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultsSources(t *testing.T) {
+	config := `servers:
+  localhost:50051:
+    real_address: localhost:50051
+    defaults:
+      output: json
+`
+	backends := map[string]*fakeBackend{
+		"localhost:50051": {health: map[string]string{"": "SERVING"}},
+	}
+	result := runCommandWithConfig(t, config, backends, "localhost:50051", "-v", "health", "--header", "x-api-key=secret")
+	if result.err != nil {
+		t.Fatalf("grpcdebug health failed: %v\n%v", result.err, result.stderr)
+	}
+	for _, want := range []string{
+		"Flag --header=[x-api-key=REDACTED] from the command line",
+		"Flag --verbose=true from the command line",
+		`Flag --output=json from the defaults of server "localhost:50051" (`,
+		"Flag --timestamp=false from its built-in default",
+	} {
+		if !strings.Contains(result.stderr, want) {
+			t.Errorf("grpcdebug -v health logged:\n%v\nwant %q", result.stderr, want)
+		}
+	}
+	if strings.Contains(result.stderr, "secret") {
+		t.Errorf("grpcdebug -v health logged the header value:\n%v", result.stderr)
+	}
+}
+
+func TestDefaultsCommandLineWins(t *testing.T) {
+	config := `servers:
+  localhost:50051:
+    real_address: localhost:50051
+    defaults:
+      output: json
+`
+	backends := map[string]*fakeBackend{
+		"localhost:50051": {health: map[string]string{"": "SERVING"}},
+	}
+	result := runCommandWithConfig(t, config, backends, "localhost:50051", "-v", "health", "-o", "yaml")
+	if result.err != nil {
+		t.Fatalf("grpcdebug health failed: %v\n%v", result.err, result.stderr)
+	}
+	if want := "Flag --output=yaml from the command line"; !strings.Contains(result.stderr, want) {
+		t.Errorf("grpcdebug -v health logged:\n%v\nwant %q", result.stderr, want)
+	}
+	if want := "- service: \"\"\n  status: SERVING\n"; result.stdout != want {
+		t.Errorf("grpcdebug health printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+}
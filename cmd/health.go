@@ -1,17 +1,23 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"sort"
 
-	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
 	"github.com/spf13/cobra"
 )
 
+// healthStatus is the health of a service, as printed by the structured
+// output formats
+type healthStatus struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+}
+
 var healthCmd = &cobra.Command{
 	Use:   "health [service names]",
 	Short: "Check health status of the target service (default \"\").",
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: forEachTarget(func(ctx context.Context, t *target, args []string) error {
 		var services []string
 		// Ensure there's the overall health status
 		services = append(services, "")
@@ -27,7 +33,8 @@ var healthCmd = &cobra.Command{
 			services[j] = services[i]
 		}
 		services = services[:j+1]
-		// Print as table
+		table := t.newTable("")
+		data := []healthStatus{}
 		for _, service := range services {
 			var serviceName string
 			if service == "" {
@@ -35,15 +42,17 @@ var healthCmd = &cobra.Command{
 			} else {
 				serviceName = service
 			}
-			fmt.Fprintf(
-				w, "%v:\t%v\t\n",
-				serviceName,
-				transport.GetHealthStatus(service),
-			)
+			status, err := t.backend.GetHealthStatus(ctx, service)
+			if err != nil {
+				t.fail(err)
+				continue
+			}
+			table.addRow(serviceName+":", status)
+			data = append(data, healthStatus{Service: service, Status: status})
 		}
-		w.Flush()
+		t.setData(data)
 		return nil
-	},
+	}),
 }
 
 func init() {
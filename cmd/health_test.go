@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	backends := map[string]*fakeBackend{
+		"localhost:50051": {health: map[string]string{"": "SERVING", "foo": "NOT_SERVING"}},
+		"localhost:50052": {health: map[string]string{"": "NOT_SERVING"}},
+	}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "table",
+			args: []string{"localhost:50051", "health", "foo"},
+			want: "<Overall>:   SERVING       \n" +
+				"foo:         NOT_SERVING   \n",
+		},
+		{
+			name: "json",
+			args: []string{"localhost:50051", "health", "foo", "-o", "json"},
+			want: `[
+  {
+    "service": "",
+    "status": "SERVING"
+  },
+  {
+    "service": "foo",
+    "status": "NOT_SERVING"
+  }
+]
+`,
+		},
+		{
+			name: "yaml",
+			args: []string{"localhost:50051", "health", "-o", "yaml"},
+			want: `- service: ""
+  status: SERVING
+`,
+		},
+		{
+			name: "csv of many targets",
+			args: []string{"localhost:50051,localhost:50052", "health", "-o", "csv"},
+			want: "localhost:50051,<Overall>,SERVING\n" +
+				"localhost:50052,<Overall>,NOT_SERVING\n",
+		},
+		{
+			name: "json of many targets",
+			args: []string{"localhost:50051,localhost:50052", "health", "-o", "json"},
+			want: `[
+  {
+    "target": "localhost:50051",
+    "data": [
+      {
+        "service": "",
+        "status": "SERVING"
+      }
+    ]
+  },
+  {
+    "target": "localhost:50052",
+    "data": [
+      {
+        "service": "",
+        "status": "NOT_SERVING"
+      }
+    ]
+  }
+]
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := runCommand(t, backends, test.args...)
+			if result.err != nil {
+				t.Fatalf("grpcdebug %v failed: %v\n%v", test.args, result.err, result.stderr)
+			}
+			if result.stdout != test.want {
+				t.Errorf("grpcdebug %v printed:\n%v\nwant:\n%v", test.args, result.stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestHealthUnreachableTarget(t *testing.T) {
+	backends := map[string]*fakeBackend{
+		"localhost:50051": {health: map[string]string{"": "SERVING"}},
+	}
+	result := runCommand(t, backends, "localhost:50051,localhost:9", "health", "-o", "json")
+	if result.err == nil || !strings.Contains(result.err.Error(), "failed on 1 of 2 targets") {
+		t.Errorf("grpcdebug health = %v, want error of 1 of 2 targets failing", result.err)
+	}
+	want := `[
+  {
+    "target": "localhost:50051",
+    "data": [
+      {
+        "service": "",
+        "status": "SERVING"
+      }
+    ]
+  },
+  {
+    "target": "localhost:9",
+    "errors": [
+      "failed to connect: connection refused (run \"grpcdebug localhost:9 ping\" to diagnose)"
+    ]
+  }
+]
+`
+	if result.stdout != want {
+		t.Errorf("grpcdebug health printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+}
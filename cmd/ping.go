@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
+	"github.com/spf13/cobra"
+)
+
+// pingPhase is a diagnosis phase, as printed by the structured output formats
+type pingPhase struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	Time   string `json:"time,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func pingCommandRunWithError(ctx context.Context, t *target, args []string) error {
+	phases := transport.Diagnose(ctx, t.config)
+	table := t.newTable("", "Phase", "Result", "Time", "Detail")
+	data := []pingPhase{}
+	var failed *transport.Phase
+	for i, phase := range phases {
+		var elapsed string
+		if phase.Status != transport.PhaseSkipped {
+			elapsed = phase.Duration.String()
+		}
+		table.addRow(phase.Name, phase.Status, elapsed, phase.Detail)
+		p := pingPhase{Name: phase.Name, Result: string(phase.Status), Time: elapsed, Detail: phase.Detail}
+		if phase.Err != nil {
+			p.Error = phase.Err.Error()
+		}
+		data = append(data, p)
+		if phase.Status == transport.PhaseFailed {
+			failed = &phases[i]
+		}
+	}
+	t.setData(data)
+	if failed != nil {
+		t.newTable("").addRow(fmt.Sprintf("%v failed: %v", failed.Name, failed.Err))
+		return fmt.Errorf("failed to connect to %v", t.address)
+	}
+	return nil
+}
+
+var pingCmd = &cobra.Command{
+	Use:         "ping",
+	Aliases:     []string{"diagnose"},
+	Short:       "Diagnose the connectivity to the target phase by phase.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipConnectAnnotation: ""},
+	RunE:        forEachTarget(pingCommandRunWithError),
+}
+
+func init() {
+	rootCmd.AddCommand(pingCmd)
+}
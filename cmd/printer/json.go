@@ -0,0 +1,199 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const jsonIndent = "  "
+
+// writeIndentedJSON writes the JSON document at the given indentation level
+func writeIndentedJSON(out io.Writer, document []byte, indent string) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, document, indent, jsonIndent); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+// protoMessageType is the type of the protobuf messages
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// objectType is the type of Object
+var objectType = reflect.TypeOf(Object(nil))
+
+// writeJSON writes the value as indented JSON at the given indentation level.
+// The protobuf messages, even in a list or an Object, or a list of Objects,
+// are written like protojson, one field at a time; the other values are
+// marshaled by encoding/json.
+func writeJSON(out io.Writer, v interface{}, indent string) error {
+	switch v := v.(type) {
+	case Object:
+		return writeObject(out, v, indent)
+	case proto.Message:
+		return writeProtoJSON(out, v, indent)
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && (rv.Type().Elem().Implements(protoMessageType) || rv.Type().Elem() == objectType) {
+		fmt.Fprint(out, "[")
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				fmt.Fprint(out, ",")
+			}
+			fmt.Fprintf(out, "\n%v%v", indent, jsonIndent)
+			if err := writeJSON(out, rv.Index(i).Interface(), indent+jsonIndent); err != nil {
+				return err
+			}
+		}
+		if rv.Len() > 0 {
+			fmt.Fprintf(out, "\n%v", indent)
+		}
+		fmt.Fprint(out, "]")
+		return nil
+	}
+	// Keeps "->" and "<Overall>" readable, unlike json.Marshal
+	var document bytes.Buffer
+	encoder := json.NewEncoder(&document)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return writeIndentedJSON(out, bytes.TrimRight(document.Bytes(), "\n"), indent)
+}
+
+// MarshalJSON marshals the Object by encoding/json, keeping the order of the
+// fields, for the legacy JSON format
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+func writeObject(out io.Writer, o Object, indent string) error {
+	fmt.Fprint(out, "{")
+	for i, field := range o {
+		if i > 0 {
+			fmt.Fprint(out, ",")
+		}
+		fmt.Fprintf(out, "\n%v%v%q: ", indent, jsonIndent, field.Name)
+		if err := writeJSON(out, field.Value, indent+jsonIndent); err != nil {
+			return err
+		}
+	}
+	if len(o) > 0 {
+		fmt.Fprintf(out, "\n%v", indent)
+	}
+	fmt.Fprint(out, "}")
+	return nil
+}
+
+// isWellKnownType reports whether the message has a special JSON mapping,
+// e.g. google.protobuf.Any, so it has to be marshaled as a whole
+func isWellKnownType(md protoreflect.MessageDescriptor) bool {
+	return strings.HasPrefix(string(md.FullName()), "google.protobuf.")
+}
+
+// writeProtoJSON writes m as indented JSON like protojson, but marshals one
+// field, or one element of a repeated field, at a time. So huge messages, e.g.
+// xDS config dumps, are never buffered as a whole once more.
+func writeProtoJSON(out io.Writer, m proto.Message, indent string) error {
+	mr := m.ProtoReflect()
+	if isWellKnownType(mr.Descriptor()) {
+		document, err := protojson.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return writeIndentedJSON(out, document, indent)
+	}
+	fmt.Fprint(out, "{")
+	fieldIndent := indent + jsonIndent
+	fields := mr.Descriptor().Fields()
+	first := true
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !mr.Has(fd) {
+			continue
+		}
+		if !first {
+			fmt.Fprint(out, ",")
+		}
+		first = false
+		fmt.Fprintf(out, "\n%v%q: ", fieldIndent, fd.JSONName())
+		var err error
+		switch {
+		case fd.IsList() && fd.Message() != nil && !isWellKnownType(fd.Message()):
+			err = writeProtoJSONList(out, mr.Get(fd).List(), fieldIndent)
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil && !isWellKnownType(fd.Message()):
+			err = writeProtoJSON(out, mr.Get(fd).Message().Interface(), fieldIndent)
+		default:
+			err = writeProtoJSONField(out, mr, fd, fieldIndent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !first {
+		fmt.Fprintf(out, "\n%v", indent)
+	}
+	fmt.Fprint(out, "}")
+	return nil
+}
+
+func writeProtoJSONList(out io.Writer, list protoreflect.List, indent string) error {
+	fmt.Fprint(out, "[")
+	for i := 0; i < list.Len(); i++ {
+		if i > 0 {
+			fmt.Fprint(out, ",")
+		}
+		fmt.Fprintf(out, "\n%v%v", indent, jsonIndent)
+		if err := writeProtoJSON(out, list.Get(i).Message().Interface(), indent+jsonIndent); err != nil {
+			return err
+		}
+	}
+	if list.Len() > 0 {
+		fmt.Fprintf(out, "\n%v", indent)
+	}
+	fmt.Fprint(out, "]")
+	return nil
+}
+
+// writeProtoJSONField writes the value of a field without nested messages to
+// stream, by marshaling a copy of the message with only this field set
+func writeProtoJSONField(out io.Writer, mr protoreflect.Message, fd protoreflect.FieldDescriptor, indent string) error {
+	single := mr.New()
+	single.Set(fd, mr.Get(fd))
+	document, err := protojson.Marshal(single.Interface())
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(document, &fields); err != nil {
+		return err
+	}
+	return writeIndentedJSON(out, fields[fd.JSONName()], indent)
+}
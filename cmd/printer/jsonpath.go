@@ -0,0 +1,523 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a template of the JSONPath syntax of kubectl, e.g.
+// "{range .items[*]}{.name}{"\n"}{end}", evaluated on the data converted by
+// toGeneric. Like kubectl, missing keys print nothing.
+type jsonPath struct {
+	nodes []jsonPathNode
+}
+
+// jsonPathNode is the text of the template, a path printing its results, a
+// quoted literal, or a range over the results of a path
+type jsonPathNode struct {
+	text    string
+	path    []jsonPathStep
+	isPath  bool
+	isRange bool
+	body    []jsonPathNode
+}
+
+// jsonPathStep selects the children of a value, e.g. ".name", "[0]" or
+// "[?(@.state=="READY")]"
+type jsonPathStep struct {
+	// field is the key selected, or "*" for all children
+	field string
+	// recursive selects the field in all descendants, e.g. "..name"
+	recursive bool
+	isIndex   bool
+	// start and end are the bounds of a slice, end is exclusive
+	start, end *int
+	isSlice    bool
+	filter     *jsonPathFilter
+}
+
+// jsonPathFilter keeps the children whose path compares to the value, or
+// exists if there is no operator
+type jsonPathFilter struct {
+	path  []jsonPathStep
+	op    string
+	value interface{}
+}
+
+// parseJSONPath parses the template given to -o jsonpath=...
+func parseJSONPath(template string) (*jsonPath, error) {
+	var stack [][]jsonPathNode
+	var ranges []jsonPathNode
+	var nodes []jsonPathNode
+	for template != "" {
+		open := strings.IndexByte(template, '{')
+		if open < 0 {
+			nodes = append(nodes, jsonPathNode{text: template})
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, jsonPathNode{text: template[:open]})
+		}
+		end, err := actionEnd(template[open:])
+		if err != nil {
+			return nil, err
+		}
+		action := strings.TrimSpace(template[open+1 : open+end])
+		template = template[open+end+1:]
+		switch {
+		case action == "end":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("{end} without a {range}")
+			}
+			r := ranges[len(ranges)-1]
+			r.body = nodes
+			nodes = append(stack[len(stack)-1], r)
+			stack, ranges = stack[:len(stack)-1], ranges[:len(ranges)-1]
+		case strings.HasPrefix(action, "range "):
+			path, err := parsePath(strings.TrimSpace(strings.TrimPrefix(action, "range ")))
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, nodes)
+			ranges = append(ranges, jsonPathNode{path: path, isRange: true})
+			nodes = nil
+		case strings.HasPrefix(action, `"`) || strings.HasPrefix(action, "'"):
+			text, err := unquote(action)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, jsonPathNode{text: text})
+		default:
+			path, err := parsePath(action)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, jsonPathNode{path: path, isPath: true})
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("{range} without an {end}")
+	}
+	return &jsonPath{nodes: nodes}, nil
+}
+
+// actionEnd returns the index of the brace closing the action the template
+// starts with, skipping the quoted strings
+func actionEnd(template string) (int, error) {
+	var quote byte
+	for i := 1; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unclosed action %q", template)
+}
+
+// unquote returns the string quoted by double or single quotes
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") && len(s) >= 2 {
+		s = `"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`
+	}
+	unquoted, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %v", s)
+	}
+	return unquoted, nil
+}
+
+// parsePath parses a path like "$.items[*].name", "@.ref" or ".", relative
+// to the current value
+func parsePath(path string) ([]jsonPathStep, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "$"), "@")
+	var steps []jsonPathStep
+	for rest != "" {
+		var step jsonPathStep
+		var err error
+		switch {
+		case rest == ".":
+			rest = ""
+			continue
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			step.field, rest = fieldName(rest[2:])
+			if step.field == "" {
+				return nil, fmt.Errorf("invalid jsonpath %q, missing a field name", path)
+			}
+		case strings.HasPrefix(rest, "."):
+			step.field, rest = fieldName(rest[1:])
+			if step.field == "" {
+				return nil, fmt.Errorf("invalid jsonpath %q, missing a field name", path)
+			}
+		case strings.HasPrefix(rest, "["):
+			step, rest, err = parseBrackets(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: %v", path, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid jsonpath %q, unexpected %q", path, rest)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// fieldName splits the field name the path starts with
+func fieldName(path string) (string, string) {
+	end := strings.IndexAny(path, ".[")
+	if end < 0 {
+		end = len(path)
+	}
+	return path[:end], path[end:]
+}
+
+// parseBrackets parses the brackets the path starts with, e.g. "[0]",
+// "[1:3]", "['name']" or "[?(@.name=="x")]"
+func parseBrackets(path string) (jsonPathStep, string, error) {
+	var step jsonPathStep
+	end, err := bracketEnd(path)
+	if err != nil {
+		return step, "", err
+	}
+	inside, rest := strings.TrimSpace(path[1:end]), path[end+1:]
+	switch {
+	case inside == "*":
+		step.field = "*"
+	case strings.HasPrefix(inside, "'") || strings.HasPrefix(inside, `"`):
+		step.field, err = unquote(inside)
+	case strings.HasPrefix(inside, "?(") && strings.HasSuffix(inside, ")"):
+		step.filter, err = parseFilter(inside[2 : len(inside)-1])
+	case strings.Contains(inside, ":"):
+		step.isSlice = true
+		bounds := strings.SplitN(inside, ":", 3)
+		for i, bound := range []**int{&step.start, &step.end} {
+			if b := strings.TrimSpace(bounds[i]); b != "" {
+				n, err := strconv.Atoi(b)
+				if err != nil {
+					return step, "", fmt.Errorf("invalid bound %q", b)
+				}
+				*bound = &n
+			}
+		}
+	default:
+		n, err := strconv.Atoi(inside)
+		if err != nil {
+			return step, "", fmt.Errorf("invalid index %q", inside)
+		}
+		step.isIndex = true
+		step.start = &n
+	}
+	return step, rest, err
+}
+
+// bracketEnd returns the index of the bracket closing the one the path starts
+// with, skipping the quoted strings and the nested brackets
+func bracketEnd(path string) (int, error) {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unclosed bracket")
+}
+
+// jsonPathOperators are the comparisons of the filters, the longest first
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseFilter parses the expression of a filter, e.g. "@.state=="READY"" or
+// "@.port > 1024"
+func parseFilter(expression string) (*jsonPathFilter, error) {
+	filter := &jsonPathFilter{}
+	left := expression
+	for _, op := range jsonPathOperators {
+		if i := strings.Index(expression, op); i >= 0 {
+			filter.op = op
+			left = expression[:i]
+			right := strings.TrimSpace(expression[i+len(op):])
+			if strings.HasPrefix(right, "'") || strings.HasPrefix(right, `"`) {
+				s, err := unquote(right)
+				if err != nil {
+					return nil, err
+				}
+				filter.value = s
+			} else if err := json.Unmarshal([]byte(right), &filter.value); err != nil {
+				return nil, fmt.Errorf("invalid value %q of the filter", right)
+			}
+			break
+		}
+	}
+	left = strings.TrimSpace(left)
+	if !strings.HasPrefix(left, "@") {
+		return nil, fmt.Errorf("invalid filter %q, expecting a path starting with @", expression)
+	}
+	path, err := parsePath(left)
+	if err != nil {
+		return nil, err
+	}
+	filter.path = path
+	return filter, nil
+}
+
+// Execute prints the template of the data
+func (j *jsonPath) Execute(out io.Writer, data interface{}) error {
+	return executeNodes(out, j.nodes, data)
+}
+
+func executeNodes(out io.Writer, nodes []jsonPathNode, current interface{}) error {
+	for _, node := range nodes {
+		switch {
+		case node.isRange:
+			results := evalPath(node.path, current)
+			if len(results) == 1 {
+				// e.g. "{range .items}", over the items
+				if list, ok := results[0].([]interface{}); ok {
+					results = list
+				}
+			}
+			for _, result := range results {
+				if err := executeNodes(out, node.body, result); err != nil {
+					return err
+				}
+			}
+		case node.isPath:
+			var texts []string
+			for _, result := range evalPath(node.path, current) {
+				text, err := jsonPathText(result)
+				if err != nil {
+					return err
+				}
+				texts = append(texts, text)
+			}
+			if _, err := io.WriteString(out, strings.Join(texts, " ")); err != nil {
+				return err
+			}
+		default:
+			if _, err := io.WriteString(out, node.text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPathText prints the strings as is, and the other values as JSON
+func jsonPathText(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	document, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(document), nil
+}
+
+// evalPath returns the values selected by the path, or none if a key is
+// missing
+func evalPath(path []jsonPathStep, current interface{}) []interface{} {
+	values := []interface{}{current}
+	for _, step := range path {
+		var next []interface{}
+		for _, v := range values {
+			if step.recursive {
+				for _, d := range descendants(v) {
+					next = append(next, step.apply(d)...)
+				}
+				continue
+			}
+			next = append(next, step.apply(v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+// children returns the values of a list, or of a map sorted by their keys
+func children(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var values []interface{}
+		for _, key := range keys {
+			values = append(values, v[key])
+		}
+		return values
+	}
+	return nil
+}
+
+// descendants returns the value and all the values it contains
+func descendants(v interface{}) []interface{} {
+	values := []interface{}{v}
+	for _, child := range children(v) {
+		values = append(values, descendants(child)...)
+	}
+	return values
+}
+
+func (s jsonPathStep) apply(v interface{}) []interface{} {
+	switch {
+	case s.field == "*":
+		return children(v)
+	case s.field != "":
+		if m, ok := v.(map[string]interface{}); ok {
+			if value, ok := m[s.field]; ok {
+				return []interface{}{value}
+			}
+		}
+		return nil
+	case s.filter != nil:
+		var kept []interface{}
+		for _, child := range children(v) {
+			if s.filter.match(child) {
+				kept = append(kept, child)
+			}
+		}
+		return kept
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	if s.isIndex {
+		i := *s.start
+		if i < 0 {
+			i += len(list)
+		}
+		if i < 0 || i >= len(list) {
+			return nil
+		}
+		return []interface{}{list[i]}
+	}
+	start, end := 0, len(list)
+	if s.start != nil {
+		start = clampIndex(*s.start, len(list))
+	}
+	if s.end != nil {
+		end = clampIndex(*s.end, len(list))
+	}
+	if start >= end {
+		return nil
+	}
+	return list[start:end]
+}
+
+// clampIndex converts a bound of a slice, which counts from the end if
+// negative, to an index of the list
+func clampIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+func (f *jsonPathFilter) match(v interface{}) bool {
+	results := evalPath(f.path, v)
+	if f.op == "" {
+		return len(results) > 0
+	}
+	for _, result := range results {
+		if compare(result, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares numbers by their values, and the other values by their
+// JSON representations
+func compare(left interface{}, op string, right interface{}) bool {
+	l, lok := toFloat(left)
+	r, rok := toFloat(right)
+	if lok && rok {
+		switch op {
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		case "<":
+			return l < r
+		case "<=":
+			return l <= r
+		case ">":
+			return l > r
+		case ">=":
+			return l >= r
+		}
+	}
+	ls, err := jsonPathText(left)
+	if err != nil {
+		return false
+	}
+	rs, err := jsonPathText(right)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case "==":
+		return ls == rs
+	case "!=":
+		return ls != rs
+	case "<":
+		return ls < rs
+	case "<=":
+		return ls <= rs
+	case ">":
+		return ls > rs
+	case ">=":
+		return ls >= rs
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// jsonPathData is the data of channelz channels, as converted by toGeneric
+var jsonPathData = []interface{}{
+	map[string]interface{}{
+		"ref":  map[string]interface{}{"channelId": "6"},
+		"data": map[string]interface{}{"state": map[string]interface{}{"state": "READY"}, "calls": int64(3)},
+	},
+	map[string]interface{}{
+		"ref":  map[string]interface{}{"channelId": "12"},
+		"data": map[string]interface{}{"state": map[string]interface{}{"state": "IDLE"}, "calls": int64(40)},
+	},
+}
+
+func TestJSONPath(t *testing.T) {
+	for _, test := range []struct {
+		template string
+		want     string
+	}{
+		{`{range [*]}{.ref.channelId} {.data.state.state}{"\n"}{end}`, "6 READY\n12 IDLE\n"},
+		{`{[*].ref.channelId}`, "6 12"},
+		{`{$[0].ref}`, `{"channelId":"6"}`},
+		{`{[-1].ref.channelId}`, "12"},
+		{`{[0:1].ref.channelId}`, "6"},
+		{`{[1:].ref.channelId}`, "12"},
+		{`{[0]['ref']['channelId']}`, "6"},
+		{`{..channelId}`, "6 12"},
+		{`{[0].data.*}`, `3 {"state":"READY"}`},
+		{`{[?(@.data.state.state=="IDLE")].ref.channelId}`, "12"},
+		{`{[?(@.data.calls > 10)].ref.channelId}`, "12"},
+		{`{[?(@.data.calls<=3)].ref.channelId}`, "6"},
+		{`{[?(@.ref.channelId)].ref.channelId}`, "6 12"},
+		{`{range .}{.ref.channelId},{end}`, "6,12,"},
+		{`{range [*]}{range .ref}{.}{end};{end}`, `{"channelId":"6"};{"channelId":"12"};`},
+		// Missing keys print nothing, like kubectl
+		{`{[0].missing.name}|{[5].ref}`, "|"},
+		{`ids: {'a'}`, "ids: a"},
+	} {
+		j, err := parseJSONPath(test.template)
+		if err != nil {
+			t.Errorf("parseJSONPath(%q) failed: %v", test.template, err)
+			continue
+		}
+		var out bytes.Buffer
+		if err := j.Execute(&out, jsonPathData); err != nil {
+			t.Errorf("Execute(%q) failed: %v", test.template, err)
+			continue
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("Execute(%q) printed %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, test := range []struct {
+		template string
+		want     string
+	}{
+		{`{.name`, "unclosed action"},
+		{`{range .items}{.name}`, "{range} without an {end}"},
+		{`{.name}{end}`, "{end} without a {range}"},
+		{`{.items[x]}`, `invalid jsonpath ".items[x]": invalid index "x"`},
+		{`{.items[0}`, `invalid jsonpath ".items[0": unclosed bracket`},
+		{`{name}`, `invalid jsonpath "name", unexpected "name"`},
+		{`{.items[?(.name=="a")]}`, "expecting a path starting with @"},
+		{`{"unterminated}`, "unclosed action"},
+	} {
+		if _, err := parseJSONPath(test.template); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parseJSONPath(%q) = %v, want error containing %q", test.template, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package printer renders the output of the commands in the format given to
+// the --output flag, so every command has the same machine-readable shape.
+package printer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// The output formats
+const (
+	FormatTable      = "table"
+	FormatWide       = "wide"
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatCSV        = "csv"
+	FormatGoTemplate = "go-template"
+	FormatJSONPath   = "jsonpath"
+)
+
+// Formats are the output formats, as given to --output
+var Formats = []string{FormatTable, FormatWide, FormatJSON, FormatYAML, FormatCSV, FormatGoTemplate + "=...", FormatJSONPath + "=..."}
+
+// Column is a column of a table, the wide ones are only printed by the wide
+// and csv formats
+type Column struct {
+	Name string
+	Wide bool
+}
+
+// Table is a table rendered by a command. Key-value tables, whose keys end
+// with a colon, have no columns.
+type Table struct {
+	// Title is printed in the separator above the table, e.g.
+	// "--- Certificate 0"
+	Title   string
+	Columns []Column
+	Rows    [][]string
+	// KeyColumn is the column of the keys of a key-value table, e.g. 1 once
+	// a leading column is added
+	KeyColumn int
+}
+
+// Field is a field of an Object
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Object is a JSON object keeping the order of its fields, whose values may
+// be protobuf messages
+type Object []Field
+
+// Documents are protobuf messages which the formats other than the
+// structured ones print as one JSON document each, like xds config did before
+// the --output flag. The structured formats print them as a list.
+type Documents []proto.Message
+
+// Printer renders the tables or the data of the commands in one format
+type Printer struct {
+	format   string
+	out      io.Writer
+	template *template.Template
+	jsonPath *jsonPath
+	// legacyJSON marshals the JSON format by encoding/json as is
+	legacyJSON bool
+}
+
+// New returns the Printer of the format given to --output, e.g. "wide" or
+// "jsonpath={.name}"
+func New(output string, out io.Writer) (*Printer, error) {
+	format, arg, hasArg := strings.Cut(output, "=")
+	p := &Printer{format: format, out: out}
+	switch format {
+	case FormatTable, FormatWide, FormatJSON, FormatYAML, FormatCSV:
+		if hasArg {
+			return nil, fmt.Errorf("output format %v doesn't take an argument", format)
+		}
+	case FormatGoTemplate:
+		if arg == "" {
+			return nil, fmt.Errorf("please give the template, e.g. -o 'go-template={{.name}}'")
+		}
+		t, err := template.New("output").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the go-template: %v", err)
+		}
+		p.template = t
+	case FormatJSONPath:
+		if arg == "" {
+			return nil, fmt.Errorf("please give the template, e.g. -o 'jsonpath={.name}'")
+		}
+		j, err := parseJSONPath(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the jsonpath template: %v", err)
+		}
+		p.jsonPath = j
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expecting one of %v", output, strings.Join(Formats, ", "))
+	}
+	return p, nil
+}
+
+// UseLegacyJSON makes the JSON format marshal the data by encoding/json as is,
+// even the protobuf messages, like the deprecated --json flag of channelz did
+func (p *Printer) UseLegacyJSON() {
+	p.legacyJSON = true
+}
+
+// Human reports whether the format prints aligned tables for humans
+func (p *Printer) Human() bool {
+	return p.format == FormatTable || p.format == FormatWide
+}
+
+// Structured reports whether the format prints the data of the commands,
+// rather than their tables
+func (p *Printer) Structured() bool {
+	switch p.format {
+	case FormatJSON, FormatYAML, FormatGoTemplate, FormatJSONPath:
+		return true
+	}
+	return false
+}
+
+// PrintTables prints the tables, separated by the titles of the tables, or
+// by blank lines in CSV
+func (p *Printer) PrintTables(tables []*Table) error {
+	if p.format == FormatCSV {
+		return p.printCSV(tables)
+	}
+	w := tabwriter.NewWriter(p.out, 10, 0, 3, ' ', 0)
+	for i, table := range tables {
+		switch {
+		case table.Title != "":
+			fmt.Fprintf(w, "--- %v\n", table.Title)
+		case i > 0:
+			fmt.Fprintln(w, "---")
+		}
+		// The wide columns are dropped
+		var keep []bool
+		var header []string
+		for _, column := range table.Columns {
+			keep = append(keep, !column.Wide || p.format == FormatWide)
+			if keep[len(keep)-1] {
+				header = append(header, column.Name)
+			}
+		}
+		if len(header) > 0 {
+			fmt.Fprintf(w, "%v\t\n", strings.Join(header, "\t"))
+		}
+		for _, row := range table.Rows {
+			var cells []string
+			for j, cell := range row {
+				if j >= len(keep) || keep[j] {
+					cells = append(cells, cell)
+				}
+			}
+			if len(cells) == 1 {
+				// e.g. a bare value, printed as is for scripts
+				fmt.Fprintln(w, cells[0])
+				continue
+			}
+			fmt.Fprintf(w, "%v\t\n", strings.Join(cells, "\t"))
+		}
+		// Each table is aligned on its own
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Printer) printCSV(tables []*Table) error {
+	w := csv.NewWriter(p.out)
+	for i, table := range tables {
+		if i > 0 {
+			w.Write(nil)
+		}
+		if len(table.Columns) > 0 {
+			var header []string
+			for _, column := range table.Columns {
+				header = append(header, column.Name)
+			}
+			w.Write(header)
+		}
+		for _, row := range table.Rows {
+			if k := table.KeyColumn; len(table.Columns) == 0 && len(row) > k {
+				row = append([]string(nil), row...)
+				row[k] = strings.TrimSuffix(row[k], ":")
+			}
+			w.Write(row)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// PrintData prints the data in the structured formats. The other formats
+// print it as JSON, e.g. the xDS configs which have no table.
+func (p *Printer) PrintData(data interface{}) error {
+	switch p.format {
+	case FormatYAML:
+		return p.printYAML(data)
+	case FormatGoTemplate, FormatJSONPath:
+		generic, err := toGeneric(data)
+		if err != nil {
+			return err
+		}
+		if p.template != nil {
+			err = p.template.Execute(p.out, generic)
+		} else {
+			err = p.jsonPath.Execute(p.out, generic)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to execute the %v template: %v", p.format, err)
+		}
+		return nil
+	}
+	if documents, ok := data.(Documents); ok && p.format != FormatJSON {
+		out := bufio.NewWriter(p.out)
+		for _, document := range documents {
+			if err := writeJSON(out, document, ""); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+		}
+		return out.Flush()
+	}
+	if p.legacyJSON && p.format == FormatJSON {
+		document, err := json.MarshalIndent(data, "", jsonIndent)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(p.out, string(document))
+		return nil
+	}
+	out := bufio.NewWriter(p.out)
+	if err := writeJSON(out, data, ""); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	return out.Flush()
+}
+
+// printYAML converts the data through JSON, keeping the order of the fields
+// and the precision of the numbers
+func (p *Printer) printYAML(data interface{}) error {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, data, ""); err != nil {
+		return err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &document); err != nil {
+		return err
+	}
+	// JSON is the flow style of YAML, which is printed in block style
+	var blockStyle func(node *yaml.Node)
+	blockStyle = func(node *yaml.Node) {
+		node.Style = 0
+		for _, child := range node.Content {
+			blockStyle(child)
+		}
+	}
+	blockStyle(&document)
+	encoder := yaml.NewEncoder(p.out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// toGeneric converts the data through JSON to maps, slices and scalars, which
+// the templates can access by their JSON names
+func toGeneric(data interface{}) (interface{}, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, data, ""); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(generic), nil
+}
+
+// normalizeNumbers replaces the JSON numbers with integers where possible, or
+// floats
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeNumbers(value)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
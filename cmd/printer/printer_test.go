@@ -0,0 +1,263 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var testTables = []*Table{
+	{
+		Columns: []Column{{Name: "Channel ID"}, {Name: "Target"}, {Name: "State"}, {Name: "Last Call Started", Wide: true}},
+		Rows: [][]string{
+			{"6", "localhost:10001", "READY", "now"},
+			{"12", "xds:///my-service", "TRANSIENT_FAILURE", "3 minutes ago"},
+		},
+	},
+	{
+		Title: "Certificate 0",
+		Rows: [][]string{
+			{"Subject:", "CN=server"},
+			{"DNS Names:", "localhost, *.test.example.com"},
+		},
+	},
+}
+
+// testData has protobuf messages at every level writeJSON handles them
+var testData = Object{
+	{Name: "target", Value: "localhost:50051"},
+	{Name: "channel", Value: &zpb.Channel{
+		Ref: &zpb.ChannelRef{ChannelId: 6, Name: "<Overall>"},
+		Data: &zpb.ChannelData{
+			State:                    &zpb.ChannelConnectivityState{State: zpb.ChannelConnectivityState_READY},
+			Target:                   "localhost:10001",
+			CallsStarted:             16908,
+			LastCallStartedTimestamp: &timestamppb.Timestamp{Seconds: 1600000000},
+		},
+	}},
+	{Name: "messages", Value: []proto.Message{
+		&zpb.SubchannelRef{SubchannelId: 7},
+		wrapperspb.String("a->b"),
+	}},
+	{Name: "sockets", Value: []*zpb.SocketRef{{SocketId: 8, Name: "s"}}},
+	{Name: "counts", Value: map[string]int{"a": 1}},
+}
+
+// render returns what the printer of the output format prints
+func render(t *testing.T, output string, print func(p *Printer) error) string {
+	t.Helper()
+	var out bytes.Buffer
+	p, err := New(output, &out)
+	if err != nil {
+		t.Fatalf("New(%q) failed: %v", output, err)
+	}
+	if err := print(p); err != nil {
+		t.Fatalf("printing %v failed: %v", output, err)
+	}
+	return out.String()
+}
+
+func TestPrintTables(t *testing.T) {
+	for _, test := range []struct {
+		output string
+		want   string
+	}{
+		{
+			output: FormatTable,
+			want: "Channel ID   Target              State               \n" +
+				"6            localhost:10001     READY               \n" +
+				"12           xds:///my-service   TRANSIENT_FAILURE   \n" +
+				"--- Certificate 0\n" +
+				"Subject:     CN=server                       \n" +
+				"DNS Names:   localhost, *.test.example.com   \n",
+		},
+		{
+			output: FormatWide,
+			want: "Channel ID   Target              State               Last Call Started   \n" +
+				"6            localhost:10001     READY               now                 \n" +
+				"12           xds:///my-service   TRANSIENT_FAILURE   3 minutes ago       \n" +
+				"--- Certificate 0\n" +
+				"Subject:     CN=server                       \n" +
+				"DNS Names:   localhost, *.test.example.com   \n",
+		},
+		{
+			output: FormatCSV,
+			want: "Channel ID,Target,State,Last Call Started\n" +
+				"6,localhost:10001,READY,now\n" +
+				"12,xds:///my-service,TRANSIENT_FAILURE,3 minutes ago\n" +
+				"\n" +
+				"Subject,CN=server\n" +
+				"DNS Names,\"localhost, *.test.example.com\"\n",
+		},
+	} {
+		t.Run(test.output, func(t *testing.T) {
+			got := render(t, test.output, func(p *Printer) error { return p.PrintTables(testTables) })
+			if got != test.want {
+				t.Errorf("PrintTables() printed:\n%v\nwant:\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintData(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "json",
+			output: FormatJSON,
+			want: `{
+  "target": "localhost:50051",
+  "channel": {
+    "ref": {
+      "channelId": "6",
+      "name": "<Overall>"
+    },
+    "data": {
+      "state": {
+        "state": "READY"
+      },
+      "target": "localhost:10001",
+      "callsStarted": "16908",
+      "lastCallStartedTimestamp": "2020-09-13T12:26:40Z"
+    }
+  },
+  "messages": [
+    {
+      "subchannelId": "7"
+    },
+    "a->b"
+  ],
+  "sockets": [
+    {
+      "socketId": "8",
+      "name": "s"
+    }
+  ],
+  "counts": {
+    "a": 1
+  }
+}
+`,
+		},
+		{
+			name:   "yaml",
+			output: FormatYAML,
+			want: `target: localhost:50051
+channel:
+  ref:
+    channelId: "6"
+    name: <Overall>
+  data:
+    state:
+      state: READY
+    target: localhost:10001
+    callsStarted: "16908"
+    lastCallStartedTimestamp: "2020-09-13T12:26:40Z"
+messages:
+  - subchannelId: "7"
+  - a->b
+sockets:
+  - socketId: "8"
+    name: s
+counts:
+  a: 1
+`,
+		},
+		{
+			name:   "go-template",
+			output: "go-template={{.target}} {{.channel.data.state.state}}{{range .sockets}} {{.socketId}}{{end}}",
+			want:   "localhost:50051 READY 8",
+		},
+		{
+			name:   "jsonpath",
+			output: "jsonpath={.channel.ref.channelId} {.sockets[*].socketId} {.messages[1]}",
+			want:   "6 8 a->b",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := render(t, test.output, func(p *Printer) error { return p.PrintData(testData) })
+			if got != test.want {
+				t.Errorf("PrintData() printed:\n%v\nwant:\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintDocuments(t *testing.T) {
+	documents := Documents{&zpb.SubchannelRef{SubchannelId: 7}, &zpb.SocketRef{SocketId: 8}}
+	// One document per message, without tables
+	got := render(t, FormatTable, func(p *Printer) error { return p.PrintData(documents) })
+	want := `{
+  "subchannelId": "7"
+}
+{
+  "socketId": "8"
+}
+`
+	if got != want {
+		t.Errorf("PrintData() printed:\n%v\nwant:\n%v", got, want)
+	}
+	// A list in JSON
+	got = render(t, FormatJSON, func(p *Printer) error { return p.PrintData(documents) })
+	want = `[
+  {
+    "subchannelId": "7"
+  },
+  {
+    "socketId": "8"
+  }
+]
+`
+	if got != want {
+		t.Errorf("PrintData() printed:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestPrintLegacyJSON(t *testing.T) {
+	var out bytes.Buffer
+	p, err := New(FormatJSON, &out)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	p.UseLegacyJSON()
+	// Marshaled by encoding/json, keeping the order of the fields
+	if err := p.PrintData(Object{{Name: "b", Value: 1}, {Name: "a", Value: []string{"x"}}}); err != nil {
+		t.Fatalf("PrintData() failed: %v", err)
+	}
+	want := `{
+  "b": 1,
+  "a": [
+    "x"
+  ]
+}
+`
+	if got := out.String(); got != want {
+		t.Errorf("PrintData() printed:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, test := range []struct {
+		output string
+		want   string
+	}{
+		{"xml", `unknown output format "xml", expecting one of table, wide, json, yaml, csv, go-template=..., jsonpath=...`},
+		{"json=x", "output format json doesn't take an argument"},
+		{"go-template", "please give the template, e.g. -o 'go-template={{.name}}'"},
+		{"go-template={{.name", "failed to parse the go-template: "},
+		{"jsonpath", "please give the template, e.g. -o 'jsonpath={.name}'"},
+		{"jsonpath={.name", "failed to parse the jsonpath template: "},
+	} {
+		if _, err := New(test.output, &bytes.Buffer{}); err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("New(%q) = %v, want error %q", test.output, err, test.want)
+		}
+	}
+}
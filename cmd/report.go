@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/printer"
+)
+
+// section is a table rendered by a command
+type section struct {
+	printer.Table
+}
+
+// addRow appends a row, formatting each cell with %v
+func (s *section) addRow(cells ...interface{}) {
+	row := make([]string, len(cells))
+	for i, cell := range cells {
+		row[i] = fmt.Sprint(cell)
+	}
+	s.Rows = append(s.Rows, row)
+}
+
+// addWideColumn appends a column only printed by the wide output
+func (s *section) addWideColumn(name string) *section {
+	s.Columns = append(s.Columns, printer.Column{Name: name, Wide: true})
+	return s
+}
+
+// key identifies the section among the sections of a report, so the same
+// section of different targets can be merged
+func (s *section) key() string {
+	var names []string
+	for _, column := range s.Columns {
+		names = append(names, column.Name)
+	}
+	return s.Title + "\x00" + strings.Join(names, "\t")
+}
+
+// report collects what a command renders for one target, so the reports of
+// many targets can be merged
+type report struct {
+	sections []*section
+	// data is printed by the structured output formats, e.g. JSON, instead of
+	// the sections. Without sections, it is printed as JSON by the other
+	// formats too.
+	data interface{}
+	// failures are the objects failed to fetch, the rest of the report is
+	// still rendered
+	failures []error
+}
+
+// newTable appends a table with the given title and header
+func (r *report) newTable(title string, header ...string) *section {
+	s := &section{printer.Table{Title: title}}
+	for _, name := range header {
+		s.Columns = append(s.Columns, printer.Column{Name: name})
+	}
+	r.sections = append(r.sections, s)
+	return s
+}
+
+// setData sets the data printed by the structured output formats, e.g. the
+// channelz objects or a protobuf message
+func (r *report) setData(data interface{}) {
+	r.data = data
+}
+
+// fail records an object failed to fetch
+func (r *report) fail(err error) {
+	r.failures = append(r.failures, err)
+}
+
+// tables returns the tables of the sections
+func (r *report) tables() []*printer.Table {
+	var tables []*printer.Table
+	for _, s := range r.sections {
+		tables = append(tables, &s.Table)
+	}
+	return tables
+}
+
+// print prints the tables of the report, or its data in the structured
+// formats and when there is no table
+func (r *report) print(p *printer.Printer) error {
+	if len(r.sections) > 0 && !p.Structured() {
+		return p.PrintTables(r.tables())
+	}
+	if r.data == nil {
+		return nil
+	}
+	return p.PrintData(r.data)
+}
+
+// printReport prints the report of a command not run against targets, e.g.
+// config list
+func printReport(r *report) error {
+	p, err := outputPrinter()
+	if err != nil {
+		return err
+	}
+	return r.print(p)
+}
@@ -4,26 +4,36 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"os"
-	"text/tabwriter"
+	"strings"
+	"time"
 
 	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
-	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/printer"
 	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var verboseFlag, timestampFlag bool
-var address, security, credFile, serverNameOverride string
-
-// The table formater
-var w = tabwriter.NewWriter(os.Stdout, 10, 0, 3, ' ', 0)
+var targetFlag, security, credFile, serverNameOverride string
+var caDir, clientCertFile, clientKeyFile string
+var headers []string
+var tokenFile, credentialHelper string
+var proxy, sshJump string
+var connectTimeout, rpcTimeout time.Duration
+var xdsBootstrap string
+var maxReceiveSize, compression string
+var allowInsecureToken bool
+var parallelismFlag int
+var eachBackendFlag bool
+var noAgentFlag bool
+var outputFlag string
 
 var rootUsageTemplate = `Usage:{{if .Runnable}}
   {{.UseLine}}{{end}}{{if .HasAvailableSubCommands}}
-  grpcdebug <target address> [flags] {{ .CommandPath | ChildCommandPath }} <command>{{end}}{{if gt (len .Aliases) 0}}
+  {{.CommandPath}} [command]{{end}}{{if gt (len .Aliases) 0}}
 
 Aliases:
   {{.NameAndAliases}}{{end}}{{if .HasExample}}
@@ -31,8 +41,11 @@ Aliases:
 Examples:
 {{.Example}}{{end}}{{if .HasAvailableSubCommands}}
 
-Available Commands:{{range .Commands}}{{if (or .IsAvailableCommand (eq .Name "help"))}}
-  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
+Available Commands:{{range .Commands}}{{if (and (eq .GroupID "") (or .IsAvailableCommand (eq .Name "help")))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{range $group := .Groups}}
+
+{{.Title}}{{range $.Commands}}{{if (and (eq .GroupID $group.ID) .IsAvailableCommand)}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
 Flags:
 {{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
@@ -43,69 +56,217 @@ Global Flags:
 Additional help topics:{{range .Commands}}{{if .IsAdditionalHelpTopicCommand}}
   {{rpad .CommandPath .CommandPathPadding}} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableSubCommands}}
 
-Use "grpcdebug <target address> {{ .CommandPath | ChildCommandPath }} [command] --help" for more information about a command.{{end}}
+Use "{{.CommandPath}} [command] --help" for more information about a command.{{end}}
 `
 
+// Commands annotated with skipConnectAnnotation connect by themselves
+const skipConnectAnnotation = "grpcdebug_skip_connect"
+
+// targetEnv is the environment variable setting the target when there is no
+// --target flag
+const targetEnv = "GRPCDEBUG_TARGET"
+
 var rootCmd = &cobra.Command{
 	Use:   "grpcdebug",
 	Short: "grpcdebug is a gRPC service admin CLI",
+	// Failures of admin RPCs are not usage errors
+	SilenceUsage: true,
 }
 
-func initConfig() {
+func initConfig(cmd *cobra.Command) {
 	if verboseFlag {
 		verbose.EnableDebugOutput()
 	}
-	c := config.GetServerConfig(address)
+}
+
+// serverConfig returns the config of the target, overridden by the flags
+func serverConfig(cfg *config.Config, address string) (config.ServerConfig, error) {
+	c, err := cfg.ServerConfig(address)
+	if err != nil {
+		return c, err
+	}
 	if credFile != "" {
 		c.CredentialFile = credFile
 	}
 	if serverNameOverride != "" {
 		c.ServerNameOverride = serverNameOverride
 	}
+	if caDir != "" {
+		c.CADir = caDir
+	}
+	if clientCertFile != "" {
+		c.ClientCertFile = clientCertFile
+	}
+	if clientKeyFile != "" {
+		c.ClientKeyFile = clientKeyFile
+	}
+	if len(headers) > 0 && c.Headers == nil {
+		c.Headers = make(map[string]string)
+	}
+	for _, header := range headers {
+		tokens := strings.SplitN(header, "=", 2)
+		if len(tokens) != 2 || tokens[0] == "" {
+			return c, fmt.Errorf("malformed header %q, expecting key=value", header)
+		}
+		c.Headers[tokens[0]] = tokens[1]
+	}
+	if tokenFile != "" {
+		c.TokenFile = tokenFile
+	}
+	if credentialHelper != "" {
+		c.CredentialHelper = credentialHelper
+	}
+	if allowInsecureToken {
+		c.AllowInsecureToken = true
+	}
+	if proxy != "" {
+		c.Proxy = proxy
+	}
+	if sshJump != "" {
+		if c.SSHJump, err = parseSSHJump(sshJump, c.SSHJump); err != nil {
+			return c, err
+		}
+	}
+	if connectTimeout != 0 {
+		c.ConnectTimeout = connectTimeout
+	}
+	if rpcTimeout != 0 {
+		c.RPCTimeout = rpcTimeout
+	}
+	if xdsBootstrap != "" {
+		c.XdsBootstrap = xdsBootstrap
+	}
+	if maxReceiveSize != "" {
+		c.MaxReceiveSize = maxReceiveSize
+	}
+	if compression != "" {
+		c.Compression = compression
+	}
 	if security == "tls" {
 		c.Security = config.TypeTLS
-		if c.CredentialFile == "" {
-			rootCmd.Usage()
-			log.Fatalf("Please specify credential file under [tls] mode.")
+		if c.CredentialFile == "" && c.CADir == "" {
+			return c, fmt.Errorf("please specify credential file or CA directory under [tls] mode")
 		}
 	} else if security != "insecure" {
-		rootCmd.Usage()
-		log.Fatalf("Unrecognized security mode: %v", security)
+		return c, fmt.Errorf("unrecognized security mode: %v", security)
+	}
+	return c, nil
+}
+
+// parseSSHJump parses the user@host[:port] flag value, keeping the key
+// settings of the configured bastion
+func parseSSHJump(value string, base *config.SSHJumpConfig) (*config.SSHJumpConfig, error) {
+	tokens := strings.SplitN(value, "@", 2)
+	if len(tokens) != 2 || tokens[0] == "" || tokens[1] == "" {
+		return nil, fmt.Errorf("malformed SSH jump host %q, expecting user@host[:port]", value)
+	}
+	jump := config.SSHJumpConfig{}
+	if base != nil {
+		jump = *base
+	}
+	jump.User = tokens[0]
+	jump.Host = tokens[1]
+	return &jump, nil
+}
+
+// resolveTarget returns the target of the commands, from the --target flag,
+// $GRPCDEBUG_TARGET or the current context, by decreasing precedence
+func resolveTarget(cfg *config.Config) (string, error) {
+	if targetFlag != "" {
+		return targetFlag, nil
+	}
+	if target := os.Getenv(targetEnv); target != "" {
+		verbose.Debugf("Using the target %v from $%v", target, targetEnv)
+		return target, nil
+	}
+	if context, ok := cfg.CurrentContext(); ok {
+		verbose.Debugf("Using the target %v of the current context %v (%v)", context.Target, context.Name, context.Origin)
+		return context.Target, nil
 	}
-	transport.Connect(c)
+	return "", fmt.Errorf("no target specified, please set one with --target, $%v or \"grpcdebug config use-context\"", targetEnv)
 }
 
-// ChildCommandPath used in template
-func ChildCommandPath(path string) string {
-	if len(path) <= 10 {
-		return ""
+// outputPrinter returns the printer of the --output format. The deprecated
+// --json flag of channelz stands for -o json, in its previous shape.
+func outputPrinter() (*printer.Printer, error) {
+	if jsonOutputFlag && outputFlag == printer.FormatTable {
+		p, err := printer.New(printer.FormatJSON, rootCmd.OutOrStdout())
+		if err != nil {
+			return nil, err
+		}
+		// Keeps the shape scripts parsing --json rely on
+		p.UseLegacyJSON()
+		return p, nil
 	}
-	return path[10:]
+	return printer.New(outputFlag, rootCmd.OutOrStdout())
 }
 
 func init() {
-	cobra.AddTemplateFunc("ChildCommandPath", ChildCommandPath)
-	cobra.OnInitialize(initConfig)
 	rootCmd.SetUsageTemplate(rootUsageTemplate)
+	// Accepts --each-backend as well as --each_backend
+	rootCmd.SetGlobalNormalizationFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		return pflag.NormalizedName(strings.ReplaceAll(name, "-", "_"))
+	})
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		initConfig(cmd)
+	}
 
+	rootCmd.PersistentFlags().StringVar(&targetFlag, "target", "", "Sets the target address, server pattern or group; comma separated for multiple targets")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", printer.FormatTable, fmt.Sprintf("Sets the output format [%v]", strings.Join(printer.Formats, ", ")))
 	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Print verbose information for debugging")
 	rootCmd.PersistentFlags().BoolVarP(&timestampFlag, "timestamp", "t", false, "Print timestamp as RFC3339 instead of human readable strings")
 	rootCmd.PersistentFlags().StringVar(&security, "security", "insecure", "Defines the type of credentials to use [tls, google-default, insecure]")
 	rootCmd.PersistentFlags().StringVar(&credFile, "credential_file", "", "Sets the path of the credential file; used in [tls] mode")
 	rootCmd.PersistentFlags().StringVar(&serverNameOverride, "server_name_override", "", "Overrides the peer server name if non empty; used in [tls] mode")
+	rootCmd.PersistentFlags().StringVar(&caDir, "ca_dir", "", "Sets the path of a directory of trusted CA bundles; used in [tls] mode")
+	rootCmd.PersistentFlags().StringVar(&clientCertFile, "client_cert_file", "", "Sets the path of the client certificate for mutual TLS; used in [tls] mode")
+	rootCmd.PersistentFlags().StringVar(&clientKeyFile, "client_key_file", "", "Sets the path of the client private key for mutual TLS; used in [tls] mode")
+	rootCmd.PersistentFlags().StringArrayVar(&headers, "header", nil, "Attaches a key=value metadata header to every admin RPC (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&tokenFile, "token_file", "", "Sets the path of a file containing a bearer token sent in the authorization header")
+	rootCmd.PersistentFlags().StringVar(&credentialHelper, "credential_helper", "", "Sets a command printing a bearer token and its expiry as JSON")
+	rootCmd.PersistentFlags().StringVar(&proxy, "proxy", "", "Sets the HTTP CONNECT proxy URL, or \"direct\" to ignore the HTTPS_PROXY environment variable")
+	rootCmd.PersistentFlags().StringVar(&sshJump, "ssh_jump", "", "Tunnels the connection through the SSH bastion user@host[:port]")
+	rootCmd.PersistentFlags().BoolVar(&allowInsecureToken, "allow_insecure_token", false, "Allows sending bearer tokens over [insecure] connections")
+	rootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect_timeout", 0, "Sets the timeout of connecting to the target (default 5s)")
+	rootCmd.PersistentFlags().DurationVar(&rpcTimeout, "rpc_timeout", 0, "Sets the timeout of each admin RPC (default 15s)")
+	rootCmd.PersistentFlags().StringVar(&maxReceiveSize, "max_receive_size", "", "Sets the max size of admin responses, e.g. 64MiB (default 4MiB)")
+	rootCmd.PersistentFlags().StringVar(&compression, "compression", "", "Compresses the admin RPCs with the given compressor [gzip]")
+	rootCmd.PersistentFlags().IntVar(&parallelismFlag, "parallelism", 10, "Sets the maximum number of targets connected concurrently")
+	rootCmd.PersistentFlags().BoolVar(&eachBackendFlag, "each_backend", false, "Resolves the target, and runs the command against each of its backends")
+	rootCmd.PersistentFlags().BoolVar(&noAgentFlag, "no_agent", false, "Connects to the targets directly, even if the agent is running")
+	rootCmd.PersistentFlags().StringVar(&xdsBootstrap, "xds_bootstrap", "", "Sets the xDS bootstrap file resolving xds:/// targets")
 }
 
 // Execute executes the root command.
 func Execute() {
-	if len(os.Args) > 1 {
-		address = os.Args[1]
-		os.Args = os.Args[1:]
-	} else {
-		rootCmd.Usage()
+	registerAliases()
+	args, err := expandAliasArgs(legacyTargetArgs(os.Args[1:]))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
+	rootCmd.SetArgs(args)
+	// The error is already printed by cobra
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+// legacyTargetArgs rewrites the target given before the command, e.g.
+// "grpcdebug localhost:50051 health", into a --target flag
+func legacyTargetArgs(args []string) []string {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	switch args[0] {
+	case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return args
+	}
+	// The help and completion commands are only added on execution
+	rootCmd.InitDefaultHelpCmd()
+	rootCmd.InitDefaultCompletionCmd()
+	if cmd, _, err := rootCmd.Find(args[:1]); err == nil && cmd != rootCmd {
+		return args
+	}
+	return append([]string{"--target", args[0]}, args[1:]...)
+}
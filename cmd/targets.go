@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/printer"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+	"github.com/spf13/cobra"
+)
+
+// newBackend connects to a target, through the agent if it's running. It can
+// be replaced to serve the commands from other backends, e.g. fakes.
+var newBackend = func(ctx context.Context, c config.ServerConfig) (transport.Backend, error) {
+	if socket, ok := transport.RunningAgent(); ok && !noAgentFlag {
+		backend, err := transport.ConnectViaAgent(ctx, socket, c)
+		if !errors.Is(err, transport.ErrAgentUnavailable) {
+			return backend, err
+		}
+		verbose.Debugf("connecting directly: %v", err)
+	}
+	return transport.Connect(ctx, c)
+}
+
+// target is one of the targets a command runs against
+type target struct {
+	// address is the target as given on the command line
+	address string
+	config  config.ServerConfig
+	// backend is nil for the commands connecting by themselves
+	backend transport.Backend
+	report
+	// err is why the command failed on this target
+	err error
+}
+
+// targetRunFunc runs a command against one target, rendering into its report
+type targetRunFunc func(ctx context.Context, t *target, args []string) error
+
+// parseTargets splits the comma separated targets, replaces the groups with
+// their members, and drops duplicates. It reports whether any group was
+// expanded, in which case the output is labelled by target.
+func parseTargets(cfg *config.Config, addresses string) ([]string, bool, error) {
+	var targets []string
+	var grouped bool
+	seen := make(map[string]bool)
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		members, ok, err := cfg.ExpandGroup(address)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			verbose.Debugf("Group %v has members %v", address, members)
+			grouped = true
+		} else {
+			members = []string{address}
+		}
+		for _, member := range members {
+			if !seen[member] {
+				seen[member] = true
+				targets = append(targets, member)
+			}
+		}
+	}
+	return targets, grouped, nil
+}
+
+// expandBackends replaces each target with its resolved backends, labelled by
+// their addresses. The targets failed to resolve are kept to report the error.
+func expandBackends(ctx context.Context, targets []*target) []*target {
+	var backends []*target
+	for _, t := range targets {
+		addresses, err := transport.ResolveBackends(ctx, t.config)
+		if err != nil {
+			t.err = err
+			backends = append(backends, t)
+			continue
+		}
+		verbose.Debugf("%v resolved to %v", t.address, addresses)
+		for _, address := range addresses {
+			backends = append(backends, &target{address: address, config: transport.BackendConfig(t.config, address)})
+		}
+	}
+	return backends
+}
+
+func (t *target) run(ctx context.Context, connect bool, run targetRunFunc, args []string) error {
+	if connect {
+		backend, err := newBackend(ctx, t.config)
+		if err != nil {
+			return fmt.Errorf("%v (run \"grpcdebug %v ping\" to diagnose)", err, t.address)
+		}
+		defer backend.Close()
+		t.backend = backend
+	}
+	return run(ctx, t, args)
+}
+
+// forEachTarget adapts run to a cobra RunE, which runs it against all targets
+// concurrently, at most parallelismFlag at a time, and renders their reports
+func forEachTarget(run targetRunFunc) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		_, skipConnect := cmd.Annotations[skipConnectAnnotation]
+		// Loaded once for all the targets
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		spec, err := resolveTarget(cfg)
+		if err != nil {
+			return err
+		}
+		addresses, grouped, err := parseTargets(cfg, spec)
+		if err != nil {
+			return err
+		}
+		if err := applyDefaults(cmd, cfg, addresses); err != nil {
+			return err
+		}
+		if parallelismFlag < 1 {
+			return fmt.Errorf("--parallelism must be positive, got %v", parallelismFlag)
+		}
+		p, err := outputPrinter()
+		if err != nil {
+			return err
+		}
+		var targets []*target
+		for _, address := range addresses {
+			c, err := serverConfig(cfg, address)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, &target{address: address, config: c})
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("no target specified")
+		}
+		if eachBackendFlag {
+			targets = expandBackends(cmd.Context(), targets)
+		}
+		tokens := make(chan struct{}, parallelismFlag)
+		var wg sync.WaitGroup
+		for _, t := range targets {
+			if t.err != nil {
+				continue
+			}
+			wg.Add(1)
+			go func(t *target) {
+				defer wg.Done()
+				tokens <- struct{}{}
+				defer func() { <-tokens }()
+				t.err = t.run(cmd.Context(), !skipConnect, run, args)
+			}(t)
+		}
+		wg.Wait()
+		if len(targets) == 1 && !eachBackendFlag && !grouped {
+			return renderTarget(p, targets[0])
+		}
+		return renderTargets(p, targets)
+	}
+}
+
+// printErrors prints the errors under the rendered output, or to stderr not
+// to break the machine-readable formats
+func printErrors(p *printer.Printer, errors []string) {
+	out := rootCmd.OutOrStdout()
+	if !p.Human() {
+		out = rootCmd.ErrOrStderr()
+	}
+	fmt.Fprintln(out, "---")
+	fmt.Fprintln(out, "Errors:")
+	for _, err := range errors {
+		fmt.Fprintf(out, "  %v\n", err)
+	}
+}
+
+// renderTarget prints the report of a single target as is
+func renderTarget(p *printer.Printer, t *target) error {
+	if err := t.print(p); err != nil {
+		return err
+	}
+	if len(t.failures) > 0 {
+		var errors []string
+		for _, err := range t.failures {
+			errors = append(errors, err.Error())
+		}
+		printErrors(p, errors)
+	}
+	if t.err != nil {
+		return t.err
+	}
+	if len(t.failures) > 0 {
+		return fmt.Errorf("failed to fetch %v object(s)", len(t.failures))
+	}
+	return nil
+}
+
+// renderTargets prints the reports of many targets. The tables are merged,
+// with a leading Target column, while the data of the targets is printed as a
+// list of objects labelled with their target.
+func renderTargets(p *printer.Printer, targets []*target) error {
+	tables := false
+	for _, t := range targets {
+		tables = tables || len(t.sections) > 0
+	}
+	var errors []string
+	var failedTargets, failures int
+	for _, t := range targets {
+		for _, err := range t.failures {
+			errors = append(errors, fmt.Sprintf("%v: %v", t.address, err))
+		}
+		failures += len(t.failures)
+		if t.err != nil {
+			errors = append(errors, fmt.Sprintf("%v: %v", t.address, t.err))
+			failedTargets++
+		}
+	}
+	if p.Structured() || !tables {
+		if err := printDataPerTarget(p, targets); err != nil {
+			return err
+		}
+	} else {
+		if err := p.PrintTables(mergeTables(targets)); err != nil {
+			return err
+		}
+		if len(errors) > 0 {
+			printErrors(p, errors)
+		}
+	}
+	if failedTargets > 0 {
+		return fmt.Errorf("failed on %v of %v targets", failedTargets, len(targets))
+	}
+	if failures > 0 {
+		return fmt.Errorf("failed to fetch %v object(s)", failures)
+	}
+	return nil
+}
+
+// mergeTables merges the same table of all targets into one, keeping the
+// order the tables appear in the reports
+func mergeTables(targets []*target) []*printer.Table {
+	var keys []string
+	merged := make(map[string]*printer.Table)
+	for _, t := range targets {
+		// A report may have many tables of the same key, e.g. untitled
+		// key-value tables, they are told apart by their occurrence.
+		occurrences := make(map[string]int)
+		insertAt := 0
+		for _, s := range t.sections {
+			key := fmt.Sprintf("%v\x00%v", s.key(), occurrences[s.key()])
+			occurrences[s.key()]++
+			m, ok := merged[key]
+			if !ok {
+				m = &printer.Table{Title: s.Title, KeyColumn: s.KeyColumn + 1}
+				if len(s.Columns) > 0 {
+					m.Columns = append([]printer.Column{{Name: "Target"}}, s.Columns...)
+				}
+				merged[key] = m
+				keys = append(keys[:insertAt], append([]string{key}, keys[insertAt:]...)...)
+			}
+			for i, k := range keys {
+				if k == key {
+					insertAt = i + 1
+				}
+			}
+			for _, row := range s.Rows {
+				m.Rows = append(m.Rows, append([]string{t.address}, row...))
+			}
+		}
+	}
+	var tables []*printer.Table
+	for _, key := range keys {
+		tables = append(tables, merged[key])
+	}
+	return tables
+}
+
+// printDataPerTarget prints the data of the targets as a single list, of an
+// object per target labelled with its target, with its errors if any
+func printDataPerTarget(p *printer.Printer, targets []*target) error {
+	var objects []printer.Object
+	for _, t := range targets {
+		o := printer.Object{{Name: "target", Value: t.address}}
+		if t.data != nil {
+			o = append(o, printer.Field{Name: "data", Value: t.data})
+		}
+		var errors []string
+		for _, err := range t.failures {
+			errors = append(errors, err.Error())
+		}
+		if t.err != nil {
+			errors = append(errors, t.err.Error())
+		}
+		if len(errors) > 0 {
+			o = append(o, printer.Field{Name: "errors", Value: errors})
+		}
+		objects = append(objects, o)
+	}
+	return p.PrintData(objects)
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	csdspb "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBackend serves canned admin states
+type fakeBackend struct {
+	channels     []*zpb.Channel
+	servers      []*zpb.Server
+	sockets      map[int64]*zpb.Socket
+	clientStatus *csdspb.ClientStatusResponse
+	// health maps the services to their status
+	health map[string]string
+}
+
+func notFound(service, object string) error {
+	return &transport.RPCError{Service: service, Object: object, Err: status.Error(codes.NotFound, "not found")}
+}
+
+func (b *fakeBackend) Channels(ctx context.Context, startID, maxResults int64) ([]*zpb.Channel, error) {
+	return b.channels, nil
+}
+
+func (b *fakeBackend) Channel(ctx context.Context, channelID int64) (*zpb.Channel, error) {
+	for _, channel := range b.channels {
+		if channel.GetRef().GetChannelId() == channelID {
+			return channel, nil
+		}
+	}
+	return nil, notFound("channelz", fmt.Sprintf("channel (id=%v)", channelID))
+}
+
+func (b *fakeBackend) Subchannel(ctx context.Context, subchannelID int64) (*zpb.Subchannel, error) {
+	return nil, notFound("channelz", fmt.Sprintf("subchannel (id=%v)", subchannelID))
+}
+
+func (b *fakeBackend) Servers(ctx context.Context, startID, maxResults int64) ([]*zpb.Server, error) {
+	return b.servers, nil
+}
+
+func (b *fakeBackend) Server(ctx context.Context, serverID int64) (*zpb.Server, error) {
+	for _, server := range b.servers {
+		if server.GetRef().GetServerId() == serverID {
+			return server, nil
+		}
+	}
+	return nil, notFound("channelz", fmt.Sprintf("server (id=%v)", serverID))
+}
+
+func (b *fakeBackend) Socket(ctx context.Context, socketID int64) (*zpb.Socket, error) {
+	if socket, ok := b.sockets[socketID]; ok {
+		return socket, nil
+	}
+	return nil, notFound("channelz", fmt.Sprintf("socket (id=%v)", socketID))
+}
+
+func (b *fakeBackend) ServerSockets(ctx context.Context, serverID, startID, maxResults int64) ([]*zpb.SocketRef, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) FetchClientStatus(ctx context.Context) (*csdspb.ClientStatusResponse, error) {
+	if b.clientStatus == nil {
+		return nil, &transport.RPCError{Service: "CSDS", Object: "xds config", Err: status.Error(codes.Unimplemented, "unknown service")}
+	}
+	return b.clientStatus, nil
+}
+
+func (b *fakeBackend) GetHealthStatus(ctx context.Context, service string) (string, error) {
+	if s, ok := b.health[service]; ok {
+		return s, nil
+	}
+	return "", notFound("health", fmt.Sprintf("service %q", service))
+}
+
+func (b *fakeBackend) Close() error {
+	return nil
+}
+
+// fakeTargets serves the fake backends of the targets, by real address
+type fakeTargets struct {
+	mu       sync.Mutex
+	backends map[string]*fakeBackend
+	// connected are the configs connected to
+	connected []config.ServerConfig
+}
+
+func (f *fakeTargets) newBackend(ctx context.Context, c config.ServerConfig) (transport.Backend, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = append(f.connected, c)
+	backend, ok := f.backends[c.RealAddress]
+	if !ok {
+		return nil, errors.New("failed to connect: connection refused")
+	}
+	return backend, nil
+}
+
+// resetFlags restores the default of every flag, since the flags of the
+// commands are global variables
+func resetFlags(cmd *cobra.Command) {
+	reset := func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, child := range cmd.Commands() {
+		resetFlags(child)
+	}
+}
+
+// commandResult is the outcome of running grpcdebug
+type commandResult struct {
+	stdout string
+	stderr string
+	err    error
+	// connected are the configs of the targets connected to
+	connected []config.ServerConfig
+}
+
+// writeConfig makes the config file with the content the only one in effect
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "grpcdebug_config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("GRPCDEBUG_CONFIG", path)
+}
+
+// runCommand runs grpcdebug with the arguments against the fake backends, by
+// real address, without any config file
+func runCommand(t *testing.T, backends map[string]*fakeBackend, args ...string) commandResult {
+	t.Helper()
+	return runCommandWithConfig(t, "", backends, args...)
+}
+
+// runCommandWithConfig runs grpcdebug like runCommand, with the config file of
+// the given contents if any. The logs of the verbose mode go to stderr.
+func runCommandWithConfig(t *testing.T, configFile string, backends map[string]*fakeBackend, args ...string) commandResult {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GRPCDEBUG_CONFIG", "")
+	if configFile != "" {
+		writeConfig(t, configFile)
+	}
+	t.Setenv(targetEnv, "")
+	targets := &fakeTargets{backends: backends}
+	original := newBackend
+	newBackend = targets.newBackend
+	defer func() { newBackend = original }()
+	resetFlags(rootCmd)
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+	log.SetOutput(&stderr)
+	defer log.SetOutput(os.Stderr)
+	registerAliases()
+	defer unregisterAliases()
+	expanded, err := expandAliasArgs(legacyTargetArgs(args))
+	if err == nil {
+		rootCmd.SetArgs(expanded)
+		err = rootCmd.Execute()
+	}
+	return commandResult{stdout: stdout.String(), stderr: stderr.String(), err: err, connected: targets.connected}
+}
+
+func TestEachBackend(t *testing.T) {
+	r := manual.NewBuilderWithScheme("test-each-backend")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{
+		{Addr: "10.0.0.1:50051"},
+		{Addr: "10.0.0.2:50051"},
+		{Addr: "10.0.0.3:50051"},
+	}})
+	resolver.Register(r)
+	backends := map[string]*fakeBackend{
+		"10.0.0.1:50051": {health: map[string]string{"": "SERVING"}},
+		"10.0.0.2:50051": {health: map[string]string{"": "NOT_SERVING"}},
+	}
+	result := runCommand(t, backends, "test-each-backend:///my-service", "health", "--each_backend", "-o", "csv")
+	if result.err == nil || !strings.Contains(result.err.Error(), "failed on 1 of 3 targets") {
+		t.Errorf("grpcdebug health --each_backend = %v, want error of 1 of 3 targets failing", result.err)
+	}
+	want := "10.0.0.1:50051,<Overall>,SERVING\n" +
+		"10.0.0.2:50051,<Overall>,NOT_SERVING\n"
+	if result.stdout != want {
+		t.Errorf("grpcdebug health --each_backend printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+	if !strings.Contains(result.stderr, "10.0.0.3:50051: failed to connect") {
+		t.Errorf("grpcdebug health --each_backend printed to stderr:\n%v\nwant the error of 10.0.0.3:50051", result.stderr)
+	}
+	var connected []string
+	for _, c := range result.connected {
+		connected = append(connected, c.RealAddress)
+	}
+	// The backends are connected to concurrently
+	sort.Strings(connected)
+	if want := []string{"10.0.0.1:50051", "10.0.0.2:50051", "10.0.0.3:50051"}; !reflect.DeepEqual(connected, want) {
+		t.Errorf("grpcdebug health --each_backend connected to %v, want %v", connected, want)
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
+	"github.com/spf13/cobra"
+)
+
+func prettyKeyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %v bits", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %v", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
+
+// tlsCertificate is a peer certificate, as printed by the structured output
+// formats
+type tlsCertificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSSANs   []string  `json:"dnsSans,omitempty"`
+	IPSANs    []string  `json:"ipSans,omitempty"`
+	URISANs   []string  `json:"uriSans,omitempty"`
+	SPIFFEIDs []string  `json:"spiffeIds,omitempty"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	KeyType   string    `json:"keyType"`
+	CA        bool      `json:"ca"`
+}
+
+// tlsCheck is the result of a verification step of the peer certificate
+type tlsCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// tlsSession is the TLS session of the target, as printed by the structured
+// output formats
+type tlsSession struct {
+	ServerName   string            `json:"serverName"`
+	Version      string            `json:"version"`
+	CipherSuite  string            `json:"cipherSuite"`
+	ALPN         string            `json:"alpn"`
+	Certificates []*tlsCertificate `json:"certificates"`
+	Checks       []tlsCheck        `json:"checks"`
+}
+
+func describeCertificate(cert *x509.Certificate) *tlsCertificate {
+	c := &tlsCertificate{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		DNSSANs:   cert.DNSNames,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		KeyType:   prettyKeyType(cert),
+		CA:        cert.IsCA,
+	}
+	for _, ip := range cert.IPAddresses {
+		c.IPSANs = append(c.IPSANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			c.SPIFFEIDs = append(c.SPIFFEIDs, uri.String())
+		} else {
+			c.URISANs = append(c.URISANs, uri.String())
+		}
+	}
+	return c
+}
+
+func printCertificate(table *section, cert *tlsCertificate) {
+	table.addRow("Subject:", cert.Subject)
+	table.addRow("Issuer:", cert.Issuer)
+	table.addRow("DNS SANs:", strings.Join(cert.DNSSANs, ", "))
+	table.addRow("IP SANs:", strings.Join(cert.IPSANs, ", "))
+	table.addRow("URI SANs:", strings.Join(cert.URISANs, ", "))
+	table.addRow("SPIFFE IDs:", strings.Join(cert.SPIFFEIDs, ", "))
+	table.addRow("Not Before:", cert.NotBefore)
+	table.addRow("Not After:", cert.NotAfter)
+	table.addRow("Key Type:", cert.KeyType)
+	table.addRow("CA:", cert.CA)
+}
+
+func tlsCommandRunWithError(ctx context.Context, t *target, args []string) error {
+	inspection, err := transport.InspectTLS(ctx, t.config)
+	if err != nil {
+		return err
+	}
+	session := &tlsSession{
+		ServerName:   inspection.ServerName,
+		Version:      inspection.Version,
+		CipherSuite:  inspection.CipherSuite,
+		ALPN:         inspection.ALPN,
+		Certificates: []*tlsCertificate{},
+		Checks:       []tlsCheck{},
+	}
+	t.setData(session)
+	table := t.newTable("")
+	table.addRow("Server Name:", inspection.ServerName)
+	table.addRow("Version:", inspection.Version)
+	table.addRow("Cipher Suite:", inspection.CipherSuite)
+	table.addRow("ALPN:", inspection.ALPN)
+	for i, cert := range inspection.PeerCertificates {
+		c := describeCertificate(cert)
+		session.Certificates = append(session.Certificates, c)
+		printCertificate(t.newTable(fmt.Sprintf("Certificate %v", i)), c)
+	}
+	var failed int
+	table = t.newTable("", "Check", "Result", "Detail")
+	for _, check := range inspection.Checks {
+		if check.Err != nil {
+			failed++
+			table.addRow(check.Name, "FAILED", check.Err)
+			session.Checks = append(session.Checks, tlsCheck{Name: check.Name, Error: check.Err.Error()})
+		} else {
+			table.addRow(check.Name, "OK", "")
+			session.Checks = append(session.Checks, tlsCheck{Name: check.Name, OK: true})
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("the peer certificate failed %v check(s)", failed)
+	}
+	return nil
+}
+
+var tlsCmd = &cobra.Command{
+	Use:         "tls",
+	Short:       "Inspect the certificate chain presented by the target.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{skipConnectAnnotation: ""},
+	RunE:        forEachTarget(tlsCommandRunWithError),
+}
+
+func init() {
+	rootCmd.AddCommand(tlsCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestDescribeCertificate(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/prod/sa/admin")
+	uri, _ := url.Parse("https://admin.example.org")
+	c := describeCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "admin"},
+		Issuer:      pkix.Name{CommonName: "test intermediate"},
+		DNSNames:    []string{"admin.test.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:        []*url.URL{spiffeID, uri},
+		PublicKey:   make(ed25519.PublicKey, ed25519.PublicKeySize),
+	})
+	want := &tlsCertificate{
+		Subject:   "CN=admin",
+		Issuer:    "CN=test intermediate",
+		DNSSANs:   []string{"admin.test.example.com"},
+		IPSANs:    []string{"10.0.0.1"},
+		URISANs:   []string{"https://admin.example.org"},
+		SPIFFEIDs: []string{"spiffe://example.org/ns/prod/sa/admin"},
+		KeyType:   "Ed25519",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("describeCertificate() = %+v, want %+v", c, want)
+	}
+}
@@ -0,0 +1,361 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// agentConfigKey is the metadata carrying the server config of the target the
+// agent forwards an admin RPC to
+const agentConfigKey = "grpcdebug-server-config-bin"
+
+// agentConnectMethod asks the agent to connect to the target, without
+// forwarding any RPC
+const agentConnectMethod = "/grpcdebug.Agent/Connect"
+
+// agentTargetErrorKey is the trailer set by the agent when it failed to
+// connect to the target, telling the target's failures from the agent's
+const agentTargetErrorKey = "grpcdebug-target-error"
+
+// agentDialTimeout bounds connecting to the agent, which is local
+const agentDialTimeout = time.Second
+
+// ErrAgentUnavailable means the agent could not be reached
+var ErrAgentUnavailable = errors.New("agent unavailable")
+
+// agentUnavailableError means the agent could not be reached, e.g. its socket
+// is gone or refuses connections, so the target can be connected to directly
+type agentUnavailableError struct {
+	socket string
+	err    error
+}
+
+func (e *agentUnavailableError) Error() string {
+	return fmt.Sprintf("failed to connect to the agent at %v: %v", e.socket, e.err)
+}
+
+func (e *agentUnavailableError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is ErrAgentUnavailable
+func (e *agentUnavailableError) Is(target error) bool {
+	return target == ErrAgentUnavailable
+}
+
+// AgentSocket returns the path of the agent's Unix socket. It is in a
+// directory only accessible by the current user, under $XDG_RUNTIME_DIR or
+// the temporary directory.
+func AgentSocket() string {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("grpcdebug-%v", os.Getuid()))
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		dir = filepath.Join(runtimeDir, "grpcdebug")
+	}
+	return filepath.Join(dir, "agent.sock")
+}
+
+// checkAgentDir verifies the directory of the agent's socket is only
+// accessible by the current user, so no one else can use or spoof the agent
+func checkAgentDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", dir)
+	}
+	return checkPrivate(dir, info)
+}
+
+// RunningAgent returns the socket of the agent if one is running for the
+// current user
+func RunningAgent() (string, bool) {
+	socket := AgentSocket()
+	if err := checkAgentDir(filepath.Dir(socket)); err != nil {
+		if !os.IsNotExist(err) {
+			verbose.Debugf("ignoring the agent: %v", err)
+		}
+		return "", false
+	}
+	if _, err := os.Stat(socket); err != nil {
+		return "", false
+	}
+	return socket, true
+}
+
+// rawCodec passes the messages through as bytes, so the agent forwards the
+// admin RPCs without knowing their types
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *(v.(*[]byte)), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*[]byte)) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// absolutePaths returns the server config with its files made absolute, as the
+// agent runs in another working directory
+func absolutePaths(c config.ServerConfig) (config.ServerConfig, error) {
+	paths := []*string{&c.CredentialFile, &c.CADir, &c.ClientCertFile, &c.ClientKeyFile, &c.TokenFile, &c.XdsBootstrap}
+	if c.SSHJump != nil {
+		sshJump := *c.SSHJump
+		c.SSHJump = &sshJump
+		paths = append(paths, &sshJump.KeyFile, &sshJump.KnownHosts)
+	}
+	for _, path := range paths {
+		if *path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return c, fmt.Errorf("failed to resolve %v: %v", *path, err)
+		}
+		*path = abs
+	}
+	return c, nil
+}
+
+// ConnectViaAgent returns the Backend querying the admin services of the
+// target through the agent listening on socket. It fails with
+// ErrAgentUnavailable if the agent could not be reached.
+func ConnectViaAgent(ctx context.Context, socket string, c config.ServerConfig) (Backend, error) {
+	c, err := absolutePaths(c)
+	if err != nil {
+		return nil, err
+	}
+	serverConfig, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	callOptions, err := newCallOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	withConfig := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, agentConfigKey, string(serverConfig))
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, agentDialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, "unix://"+socket,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		// A socket left by an agent which didn't exit cleanly refuses
+		// connections, or is gone, which fails at once instead of retrying
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithReturnConnectionError(),
+		grpc.WithDefaultCallOptions(callOptions...),
+		grpc.WithChainUnaryInterceptor(withConfig),
+	)
+	if err != nil {
+		return nil, &agentUnavailableError{socket: socket, err: err}
+	}
+	verbose.Debugf("Connecting to %v through the agent at %v", c.RealAddress, socket)
+	connectTimeout, rpcTimeout := timeouts(c)
+	ctx, cancel = context.WithTimeout(ctx, connectTimeout+agentDialTimeout)
+	defer cancel()
+	var req, resp []byte
+	var trailer metadata.MD
+	if err := conn.Invoke(ctx, agentConnectMethod, &req, &resp, grpc.ForceCodec(rawCodec{}), grpc.Trailer(&trailer)); err != nil {
+		conn.Close()
+		if status.Code(err) == codes.Unavailable && len(trailer.Get(agentTargetErrorKey)) == 0 {
+			// The agent went away
+			return nil, &agentUnavailableError{socket: socket, err: err}
+		}
+		return nil, errors.New(status.Convert(err).Message())
+	}
+	return newGRPCBackend(conn, rpcTimeout), nil
+}
+
+// agentConn is a warm connection kept by the agent
+type agentConn struct {
+	// ready is closed once dialing finished
+	ready chan struct{}
+	conn  *clientConn
+	err   error
+	// lastUsed is guarded by the agent's mu
+	lastUsed time.Time
+}
+
+type agentServer struct {
+	idleTimeout time.Duration
+	mu          sync.Mutex
+	// conns are keyed by connKey
+	conns map[string]*agentConn
+}
+
+// connKey identifies the connection to the target of the server config. The
+// token file is only read when dialing, so its content is part of the key,
+// and a rewritten token gets a new connection.
+func connKey(serverConfig string, c config.ServerConfig) string {
+	if c.TokenFile == "" {
+		return serverConfig
+	}
+	token, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		// Dialing reports the error
+		return serverConfig
+	}
+	return fmt.Sprintf("%v\x00%x", serverConfig, sha256.Sum256(token))
+}
+
+// conn returns the connection to the target, dialing it if there is none
+func (a *agentServer) conn(ctx context.Context, key string, c config.ServerConfig) (*clientConn, error) {
+	a.mu.Lock()
+	ac, ok := a.conns[key]
+	if !ok {
+		ac = &agentConn{ready: make(chan struct{})}
+		a.conns[key] = ac
+	}
+	ac.lastUsed = time.Now()
+	a.mu.Unlock()
+	if !ok {
+		// Not bound to the RPC, which may be canceled while others wait
+		ac.conn, ac.err = dial(context.Background(), c)
+		if ac.err != nil {
+			a.mu.Lock()
+			delete(a.conns, key)
+			a.mu.Unlock()
+		} else {
+			verbose.Debugf("agent: connected to %v", c.RealAddress)
+		}
+		close(ac.ready)
+	}
+	select {
+	case <-ac.ready:
+		return ac.conn, ac.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// forward forwards the admin RPC to the target in its metadata
+func (a *agentServer) forward(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	values := md.Get(agentConfigKey)
+	if len(values) != 1 {
+		return status.Errorf(codes.InvalidArgument, "expecting one %v metadata", agentConfigKey)
+	}
+	var c config.ServerConfig
+	if err := json.Unmarshal([]byte(values[0]), &c); err != nil {
+		return status.Errorf(codes.InvalidArgument, "malformed server config: %v", err)
+	}
+	conn, err := a.conn(stream.Context(), connKey(values[0], c), c)
+	if err != nil {
+		stream.SetTrailer(metadata.Pairs(agentTargetErrorKey, "true"))
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	var req, resp []byte
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	if method == agentConnectMethod {
+		return stream.SendMsg(&resp)
+	}
+	if err := conn.Invoke(stream.Context(), method, &req, &resp, grpc.ForceCodec(rawCodec{})); err != nil {
+		return err
+	}
+	return stream.SendMsg(&resp)
+}
+
+// expireIdleConns closes the connections unused for idleTimeout
+func (a *agentServer) expireIdleConns() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, ac := range a.conns {
+		select {
+		case <-ac.ready:
+		default:
+			// Still dialing
+			continue
+		}
+		if time.Since(ac.lastUsed) > a.idleTimeout {
+			verbose.Debugf("agent: closing idle connection to %v", ac.conn.Target())
+			ac.conn.Close()
+			delete(a.conns, key)
+		}
+	}
+}
+
+func (a *agentServer) closeAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, ac := range a.conns {
+		if ac.conn != nil {
+			ac.conn.Close()
+		}
+		delete(a.conns, key)
+	}
+}
+
+// ServeAgent serves the agent on the socket until ctx is done. The agent keeps
+// warm connections to the targets, closing those idle for idleTimeout.
+func ServeAgent(ctx context.Context, idleTimeout time.Duration) error {
+	socket := AgentSocket()
+	dir := filepath.Dir(socket)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create the agent directory: %v", err)
+	}
+	if err := checkAgentDir(dir); err != nil {
+		return fmt.Errorf("refusing to serve the agent: %v", err)
+	}
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.DialTimeout("unix", socket, agentDialTimeout); err == nil {
+			conn.Close()
+			return fmt.Errorf("an agent is already running at %v", socket)
+		}
+		// Left by an agent which didn't exit cleanly
+		os.Remove(socket)
+	}
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %v", socket, err)
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		lis.Close()
+		return fmt.Errorf("failed to restrict the agent socket: %v", err)
+	}
+	a := &agentServer{idleTimeout: idleTimeout, conns: make(map[string]*agentConn)}
+	server := grpc.NewServer(grpc.UnknownServiceHandler(a.forward), grpc.ForceServerCodec(rawCodec{}))
+	go func() {
+		ticker := time.NewTicker(idleTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.expireIdleConns()
+			case <-ctx.Done():
+				server.GracefulStop()
+				return
+			}
+		}
+	}()
+	fmt.Printf("Serving the agent on %v\n", socket)
+	err = server.Serve(lis)
+	a.closeAll()
+	os.Remove(socket)
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package transport
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkPrivate fails on platforms without Unix file permissions, where the
+// agent's socket can't be restricted to the current user
+func checkPrivate(path string, info os.FileInfo) error {
+	return fmt.Errorf("the agent is only supported on Unix")
+}
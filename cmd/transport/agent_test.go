@@ -0,0 +1,134 @@
+//go:build unix
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+)
+
+// startAgent serves the agent in a runtime directory of the test, and returns
+// its socket
+func startAgent(t *testing.T) string {
+	t.Helper()
+	// Unix socket paths are short, so the directory isn't under t.TempDir()
+	runtimeDir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatalf("failed to create runtime directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(runtimeDir) })
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ServeAgent(ctx, time.Minute) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if socket, ok := RunningAgent(); ok {
+			return socket
+		}
+	}
+	t.Fatalf("the agent didn't start")
+	return ""
+}
+
+func TestConnectViaAgent(t *testing.T) {
+	socket := startAgent(t)
+	backend, err := ConnectViaAgent(context.Background(), socket, config.ServerConfig{RealAddress: startHealthServer(t)})
+	if err != nil {
+		t.Fatalf("ConnectViaAgent() failed: %v", err)
+	}
+	defer backend.Close()
+	status, err := backend.GetHealthStatus(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetHealthStatus() failed: %v", err)
+	}
+	if status != "SERVING" {
+		t.Errorf("GetHealthStatus() = %v, want SERVING", status)
+	}
+}
+
+func TestConnectViaAgentTargetError(t *testing.T) {
+	socket := startAgent(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	// Nothing listens on the target anymore
+	target := listener.Addr().String()
+	listener.Close()
+	_, err = ConnectViaAgent(context.Background(), socket, config.ServerConfig{RealAddress: target, ConnectTimeout: time.Second})
+	if err == nil {
+		t.Fatalf("ConnectViaAgent() succeeded, want error")
+	}
+	if errors.Is(err, ErrAgentUnavailable) {
+		t.Errorf("ConnectViaAgent() = %v, want the error of the target, not of the agent", err)
+	}
+}
+
+func TestConnectViaAgentMissingSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	_, err := ConnectViaAgent(context.Background(), socket, config.ServerConfig{RealAddress: "localhost:50051"})
+	if !errors.Is(err, ErrAgentUnavailable) {
+		t.Errorf("ConnectViaAgent() = %v, want ErrAgentUnavailable", err)
+	}
+}
+
+func TestConnectViaAgentCanceled(t *testing.T) {
+	socket := startAgent(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ConnectViaAgent(ctx, socket, config.ServerConfig{RealAddress: startHealthServer(t)})
+	if err == nil {
+		t.Errorf("ConnectViaAgent() succeeded with a canceled context, want error")
+	}
+}
+
+func TestAbsolutePaths(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	sshJump := &config.SSHJumpConfig{Host: "bastion", KeyFile: "id_ed25519", KnownHosts: "/etc/ssh/known_hosts"}
+	c, err := absolutePaths(config.ServerConfig{
+		CredentialFile: "ca.pem",
+		CADir:          "cas",
+		ClientCertFile: "certs/client.pem",
+		ClientKeyFile:  "certs/client.key",
+		TokenFile:      "../token",
+		XdsBootstrap:   "bootstrap.json",
+		SSHJump:        sshJump,
+	})
+	if err != nil {
+		t.Fatalf("absolutePaths() failed: %v", err)
+	}
+	for _, test := range []struct{ got, want string }{
+		{c.CredentialFile, filepath.Join(wd, "ca.pem")},
+		{c.CADir, filepath.Join(wd, "cas")},
+		{c.ClientCertFile, filepath.Join(wd, "certs/client.pem")},
+		{c.ClientKeyFile, filepath.Join(wd, "certs/client.key")},
+		{c.TokenFile, filepath.Join(filepath.Dir(wd), "token")},
+		{c.XdsBootstrap, filepath.Join(wd, "bootstrap.json")},
+		{c.SSHJump.KeyFile, filepath.Join(wd, "id_ed25519")},
+		{c.SSHJump.KnownHosts, "/etc/ssh/known_hosts"},
+	} {
+		if test.got != test.want {
+			t.Errorf("absolutePaths() made %v, want %v", test.got, test.want)
+		}
+	}
+	if sshJump.KeyFile != "id_ed25519" {
+		t.Errorf("absolutePaths() modified the config it was given: key file %v", sshJump.KeyFile)
+	}
+	if c, _ := absolutePaths(config.ServerConfig{}); c.CredentialFile != "" || c.SSHJump != nil {
+		t.Errorf("absolutePaths() = %+v, want the empty paths left empty", c)
+	}
+}
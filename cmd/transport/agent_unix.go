@@ -0,0 +1,25 @@
+//go:build unix
+
+package transport
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivate verifies the file is owned, and only accessible, by the
+// current user
+func checkPrivate(path string, info os.FileInfo) error {
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%v is accessible by other users (mode %v)", path, info.Mode().Perm())
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("failed to get the owner of %v", path)
+	}
+	if int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%v is owned by another user (uid %v)", path, stat.Uid)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"context"
+
+	csdspb "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
+	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+)
+
+// ChannelzBackend serves the channelz states of a target
+type ChannelzBackend interface {
+	// Channels returns the top channels, starting from startID
+	Channels(ctx context.Context, startID, maxResults int64) ([]*zpb.Channel, error)
+	// Channel returns the channel with given channel ID
+	Channel(ctx context.Context, channelID int64) (*zpb.Channel, error)
+	// Subchannel returns the subchannel with given subchannel ID
+	Subchannel(ctx context.Context, subchannelID int64) (*zpb.Subchannel, error)
+	// Servers returns the servers, starting from startID
+	Servers(ctx context.Context, startID, maxResults int64) ([]*zpb.Server, error)
+	// Server returns the server with given server ID
+	Server(ctx context.Context, serverID int64) (*zpb.Server, error)
+	// Socket returns the socket with given socket ID
+	Socket(ctx context.Context, socketID int64) (*zpb.Socket, error)
+	// ServerSockets returns the references to the sockets of the server
+	ServerSockets(ctx context.Context, serverID, startID, maxResults int64) ([]*zpb.SocketRef, error)
+}
+
+// CSDSBackend serves the xDS client status of a target
+type CSDSBackend interface {
+	// FetchClientStatus fetches the xDS resources status
+	FetchClientStatus(ctx context.Context) (*csdspb.ClientStatusResponse, error)
+}
+
+// HealthBackend serves the health checking status of a target
+type HealthBackend interface {
+	// GetHealthStatus fetches the health checking status of the service
+	GetHealthStatus(ctx context.Context, service string) (string, error)
+}
+
+// Backend is the source of the admin states rendered by the commands. The
+// live implementation queries the admin services of a target over gRPC, other
+// implementations may serve snapshots, recorded sessions or fakes.
+//
+// Implementations are expected to return failures as *RPCError, so the
+// commands can classify them.
+type Backend interface {
+	ChannelzBackend
+	CSDSBackend
+	HealthBackend
+	// Close releases the resources of the backend
+	Close() error
+}
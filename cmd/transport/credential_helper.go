@@ -0,0 +1,184 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+)
+
+// Tokens expiring within this window are considered expired, so they won't
+// expire in the middle of a command.
+const tokenExpiryMargin = time.Minute
+
+// helperToken is the JSON output of a credential helper
+type helperToken struct {
+	Token string `json:"token"`
+	// Expiry is optional, tokens without expiry are never cached on disk
+	Expiry time.Time `json:"expiry"`
+}
+
+func (t *helperToken) valid() bool {
+	return t.Token != "" && time.Now().Add(tokenExpiryMargin).Before(t.Expiry)
+}
+
+// execCredentials obtains bearer tokens by running a credential helper
+// command, similar to the exec credential plugins of kubectl
+type execCredentials struct {
+	command string
+	args    []string
+	mu      sync.Mutex
+	token   *helperToken
+}
+
+func newExecCredentials(command string) (*execCredentials, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credential helper command: %v", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("credential helper command is empty")
+	}
+	return &execCredentials{command: command, args: args}, nil
+}
+
+// splitCommand splits the command line into arguments like a shell does,
+// honoring single quotes, double quotes and backslashes, but without
+// expanding anything, e.g. `"/opt/my tools/token" --scope 'a b'`
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	// inArg tells whether an argument is started, even if empty, e.g. ''
+	inArg := false
+	var quote rune
+	escaped := false
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case quote == '"':
+			switch c {
+			case '"':
+				quote = 0
+			case '\\':
+				// Like in a shell, only quotes and backslashes are escaped
+				if i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+					c = runes[i]
+				}
+				arg.WriteRune(c)
+			default:
+				arg.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inArg = true, true
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, command)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in %q", command)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func (e *execCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token == nil || (!e.token.Expiry.IsZero() && !e.token.valid()) {
+		token, err := e.fetchToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e.token = token
+	}
+	return map[string]string{authorizationHeader: "Bearer " + e.token.Token}, nil
+}
+
+// RequireTransportSecurity returns false, since whether a token may be sent
+// without TLS is checked ahead of dialing.
+func (e *execCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// cachePath returns the path of the on disk cache of this helper's tokens
+func (e *execCredentials) cachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(e.command))
+	return filepath.Join(dir, "grpcdebug", "credentials", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func (e *execCredentials) fetchToken(ctx context.Context) (*helperToken, error) {
+	cachePath, err := e.cachePath()
+	if err != nil {
+		verbose.Debugf("credential cache disabled: %v", err)
+	} else if cached, err := os.ReadFile(cachePath); err == nil {
+		var token helperToken
+		if err := json.Unmarshal(cached, &token); err == nil && token.valid() {
+			verbose.Debugf("Using cached token from %v, expires at %v", cachePath, token.Expiry)
+			return &token, nil
+		}
+	}
+	verbose.Debugf("Running credential helper %v", e.args[0])
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.args[0], e.args[1:]...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var token helperToken
+	if err := json.Unmarshal(output, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse credential helper output: %v", err)
+	}
+	if token.Token == "" {
+		return nil, fmt.Errorf("credential helper returned an empty token")
+	}
+	if cachePath != "" && !token.Expiry.IsZero() {
+		// The cache holds secrets, so it is only accessible by the current user
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err == nil {
+			err = os.WriteFile(cachePath, output, 0600)
+		}
+		if err != nil {
+			verbose.Debugf("failed to cache token: %v", err)
+		}
+	}
+	return &token, nil
+}
@@ -0,0 +1,226 @@
+package transport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, test := range []struct {
+		command string
+		want    []string
+		wantErr string
+	}{
+		{command: "gcloud auth print-access-token", want: []string{"gcloud", "auth", "print-access-token"}},
+		{command: `"/opt/my tools/token" --scope 'a b'`, want: []string{"/opt/my tools/token", "--scope", "a b"}},
+		{command: `token --name=my\ service ''`, want: []string{"token", "--name=my service", ""}},
+		{command: `echo "a \"b\" \c" 'd\e'`, want: []string{"echo", `a "b" \c`, `d\e`}},
+		{command: "  ", want: nil},
+		{command: `token 'unterminated`, wantErr: `unterminated ' quote in "token 'unterminated"`},
+		{command: `token \`, wantErr: `trailing backslash in "token \\"`},
+	} {
+		got, err := splitCommand(test.command)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("splitCommand(%q) = %q, %v, want error %q", test.command, got, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitCommand(%q) = %q, %v, want %q", test.command, got, err, test.want)
+		}
+	}
+}
+
+// writeHelper writes a credential helper printing its first argument as the
+// token, with the expiry of $TOKEN_EXPIRY, and counting its runs in a file.
+// Its path has a space, which has to be quoted.
+func writeHelper(t *testing.T) (string, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "my tools")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	runs := filepath.Join(dir, "runs")
+	helper := filepath.Join(dir, "token.sh")
+	script := "#!/bin/sh\necho run >> '" + runs + "'\nprintf '{\"token\": \"%s\", \"expiry\": \"%s\"}' \"$1\" \"$TOKEN_EXPIRY\"\n"
+	if err := os.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write credential helper: %v", err)
+	}
+	return `"` + helper + `"`, runs
+}
+
+// helperRuns returns how many times the helper ran
+func helperRuns(t *testing.T, runs string) int {
+	t.Helper()
+	data, err := os.ReadFile(runs)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("failed to read runs: %v", err)
+	}
+	return strings.Count(string(data), "run\n")
+}
+
+func getToken(t *testing.T, command string) string {
+	t.Helper()
+	creds, err := newExecCredentials(command)
+	if err != nil {
+		t.Fatalf("newExecCredentials() failed: %v", err)
+	}
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() failed: %v", err)
+	}
+	return md[authorizationHeader]
+}
+
+func TestExecCredentialsCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	helper, runs := writeHelper(t)
+	command := helper + " 'my token'"
+	t.Setenv("TOKEN_EXPIRY", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+
+	if got := getToken(t, command); got != "Bearer my token" {
+		t.Errorf("authorization = %q, want %q", got, "Bearer my token")
+	}
+	creds, _ := newExecCredentials(command)
+	cache, err := creds.cachePath()
+	if err != nil {
+		t.Fatalf("cachePath() failed: %v", err)
+	}
+	info, err := os.Stat(cache)
+	if err != nil {
+		t.Fatalf("token isn't cached: %v", err)
+	}
+	// The cache holds secrets
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("cache file mode = %v, want 0600", mode)
+	}
+	info, err = os.Stat(filepath.Dir(cache))
+	if err != nil {
+		t.Fatalf("failed to stat cache directory: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0700 {
+		t.Errorf("cache directory mode = %v, want 0700", mode)
+	}
+
+	// Another command hits the cache
+	if got := getToken(t, command); got != "Bearer my token" {
+		t.Errorf("authorization = %q, want %q", got, "Bearer my token")
+	}
+	if got := helperRuns(t, runs); got != 1 {
+		t.Errorf("helper ran %v times, want once", got)
+	}
+
+	// A token expiring within the margin is fetched again
+	t.Setenv("TOKEN_EXPIRY", time.Now().Add(tokenExpiryMargin/2).UTC().Format(time.RFC3339))
+	if err := os.Remove(cache); err != nil {
+		t.Fatalf("failed to remove cache: %v", err)
+	}
+	getToken(t, command)
+	getToken(t, command)
+	if got := helperRuns(t, runs); got != 3 {
+		t.Errorf("helper ran %v times, want 3, the expiring token isn't cached", got)
+	}
+}
+
+func TestExecCredentialsWithoutExpiry(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	helper, runs := writeHelper(t)
+	script := "#!/bin/sh\necho run >> '" + runs + "'\necho '{\"token\": \"t\"}'\n"
+	if err := os.WriteFile(strings.Trim(helper, `"`), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write credential helper: %v", err)
+	}
+	creds, err := newExecCredentials(helper)
+	if err != nil {
+		t.Fatalf("newExecCredentials() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := creds.GetRequestMetadata(context.Background()); err != nil {
+			t.Fatalf("GetRequestMetadata() failed: %v", err)
+		}
+	}
+	// Kept in memory for the command, but never on disk
+	if got := helperRuns(t, runs); got != 1 {
+		t.Errorf("helper ran %v times, want once", got)
+	}
+	cache, _ := creds.cachePath()
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Errorf("token without expiry is cached at %v", cache)
+	}
+}
+
+func TestExecCredentialsErrors(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	if _, err := newExecCredentials(`token 'a`); err == nil || !strings.HasPrefix(err.Error(), "failed to parse credential helper command: ") {
+		t.Errorf("newExecCredentials() = %v, want error of the quote", err)
+	}
+	if _, err := newExecCredentials(" "); err == nil || err.Error() != "credential helper command is empty" {
+		t.Errorf("newExecCredentials() = %v, want error of the empty command", err)
+	}
+	creds, err := newExecCredentials("sh -c 'echo denied >&2; exit 1'")
+	if err != nil {
+		t.Fatalf("newExecCredentials() failed: %v", err)
+	}
+	if _, err := creds.GetRequestMetadata(context.Background()); err == nil || !strings.HasSuffix(err.Error(), ": denied") {
+		t.Errorf("GetRequestMetadata() = %v, want error with the stderr of the helper", err)
+	}
+	creds, _ = newExecCredentials("echo not json")
+	if _, err := creds.GetRequestMetadata(context.Background()); err == nil || !strings.HasPrefix(err.Error(), "failed to parse credential helper output") {
+		t.Errorf("GetRequestMetadata() = %v, want error of the output", err)
+	}
+}
+
+func TestAuthorizationPrecedence(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("t\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	for _, test := range []struct {
+		name    string
+		config  config.ServerConfig
+		wantErr string
+	}{
+		{
+			name:   "token file",
+			config: config.ServerConfig{TokenFile: tokenFile, Headers: map[string]string{"x-team": "infra"}},
+		},
+		{
+			name:    "header and token file",
+			config:  config.ServerConfig{TokenFile: tokenFile, Headers: map[string]string{"Authorization": "Bearer h"}},
+			wantErr: "the authorization header and token_file set the authorization header together, please set only one of them",
+		},
+		{
+			name:    "token file and helper",
+			config:  config.ServerConfig{TokenFile: tokenFile, CredentialHelper: "token"},
+			wantErr: "token_file and credential_helper set the authorization header together, please set only one of them",
+		},
+		{
+			name:    "all of them",
+			config:  config.ServerConfig{TokenFile: tokenFile, CredentialHelper: "token", Headers: map[string]string{"authorization": "Bearer h"}},
+			wantErr: "the authorization header, token_file and credential_helper set the authorization header together, please set only one of them",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newPerRPCCredentials(test.config, true)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("newPerRPCCredentials() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("newPerRPCCredentials() = %v, want error %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"google.golang.org/grpc/credentials"
+)
+
+const authorizationHeader = "authorization"
+
+// headerCredentials attaches a fixed set of metadata to every RPC
+type headerCredentials struct {
+	headers map[string]string
+}
+
+func (h *headerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return h.headers, nil
+}
+
+// RequireTransportSecurity returns false, since whether a token may be sent
+// without TLS is checked ahead of dialing, see newHeaderCredentials.
+func (h *headerCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// newHeaderCredentials builds the per-RPC credentials carrying the headers and
+// the bearer token of c. It returns nil if there is nothing to attach.
+func newHeaderCredentials(c config.ServerConfig, secure bool) (*headerCredentials, error) {
+	headers := make(map[string]string)
+	for key, value := range c.Headers {
+		// Metadata keys are always lowercase on the wire
+		headers[strings.ToLower(key)] = value
+	}
+	if c.TokenFile != "" {
+		token, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %v", err)
+		}
+		headers[authorizationHeader] = "Bearer " + strings.TrimSpace(string(token))
+	}
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	if _, ok := headers[authorizationHeader]; ok {
+		if err := checkTokenAllowed(c, secure); err != nil {
+			return nil, err
+		}
+	}
+	return &headerCredentials{headers: headers}, nil
+}
+
+// checkTokenAllowed returns an error if bearer tokens must not be sent
+func checkTokenAllowed(c config.ServerConfig, secure bool) error {
+	if !secure && !c.AllowInsecureToken {
+		return fmt.Errorf("refusing to send bearer token over an insecure connection, use --allow_insecure_token to override")
+	}
+	return nil
+}
+
+// checkAuthorization returns an error if more than one setting of c sets the
+// authorization header, since none of them would obviously take precedence
+func checkAuthorization(c config.ServerConfig) error {
+	var settings []string
+	for key := range c.Headers {
+		if strings.ToLower(key) == authorizationHeader {
+			settings = append(settings, "the authorization header")
+			break
+		}
+	}
+	if c.TokenFile != "" {
+		settings = append(settings, "token_file")
+	}
+	if c.CredentialHelper != "" {
+		settings = append(settings, "credential_helper")
+	}
+	if len(settings) > 1 {
+		last := len(settings) - 1
+		return fmt.Errorf("%v and %v set the authorization header together, please set only one of them", strings.Join(settings[:last], ", "), settings[last])
+	}
+	return nil
+}
+
+// newPerRPCCredentials returns all per-RPC credentials configured by c
+func newPerRPCCredentials(c config.ServerConfig, secure bool) ([]credentials.PerRPCCredentials, error) {
+	if err := checkAuthorization(c); err != nil {
+		return nil, err
+	}
+	var creds []credentials.PerRPCCredentials
+	headerCreds, err := newHeaderCredentials(c, secure)
+	if err != nil {
+		return nil, err
+	}
+	if headerCreds != nil {
+		creds = append(creds, headerCreds)
+	}
+	if c.CredentialHelper != "" {
+		if err := checkTokenAllowed(c, secure); err != nil {
+			return nil, err
+		}
+		execCreds, err := newExecCredentials(c.CredentialHelper)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, execCreds)
+	}
+	return creds, nil
+}
@@ -0,0 +1,262 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"golang.org/x/net/http2"
+)
+
+// PhaseStatus is the outcome of a diagnosis phase
+type PhaseStatus string
+
+const (
+	// PhaseOK means the phase succeeded
+	PhaseOK PhaseStatus = "OK"
+	// PhaseFailed means the phase failed, the following phases are skipped
+	PhaseFailed PhaseStatus = "FAILED"
+	// PhaseSkipped means the phase does not apply to this target
+	PhaseSkipped PhaseStatus = "SKIPPED"
+)
+
+// Phase is the result of one phase of the connectivity diagnosis
+type Phase struct {
+	Name     string
+	Status   PhaseStatus
+	Duration time.Duration
+	// Detail describes the outcome, e.g. the resolved addresses
+	Detail string
+	// Err explains why the phase failed
+	Err error
+}
+
+// diagnosis walks the phases of connecting to a target one by one
+type diagnosis struct {
+	c      config.ServerConfig
+	phases []Phase
+}
+
+// run runs the phase f, and records its outcome. It returns false if the
+// phase failed.
+func (d *diagnosis) run(name string, f func() (string, error)) bool {
+	start := time.Now()
+	detail, err := f()
+	phase := Phase{Name: name, Status: PhaseOK, Duration: time.Since(start), Detail: detail, Err: err}
+	if err != nil {
+		phase.Status = PhaseFailed
+	}
+	d.phases = append(d.phases, phase)
+	return err == nil
+}
+
+func (d *diagnosis) skip(name, reason string) {
+	d.phases = append(d.phases, Phase{Name: name, Status: PhaseSkipped, Detail: reason})
+}
+
+// dialTarget splits a gRPC target into the network and address to dial. It
+// returns an empty network if the target is resolved by other resolvers, e.g.
+// xds:///.
+func dialTarget(target string) (string, string) {
+	if strings.HasPrefix(target, "unix:") {
+		return "unix", target
+	}
+	if strings.HasPrefix(target, "unix-abstract:") {
+		return "unix", "\x00" + strings.TrimPrefix(target, "unix-abstract:")
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		// Targets like "localhost:50051" parse with scheme "localhost"
+		return "tcp", target
+	}
+	switch u.Scheme {
+	case "dns", "passthrough":
+		return "tcp", strings.TrimPrefix(u.Path, "/")
+	}
+	return "", target
+}
+
+// defaultServerName returns the TLS server name gRPC derives from the target
+func defaultServerName(network, address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil && network == "tcp" {
+		return host
+	}
+	return "localhost"
+}
+
+// explainTLSError turns handshake failures into actionable explanations
+func explainTLSError(err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("the server certificate is not signed by a trusted CA, check credential_file or ca_dir: %v", err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("the server certificate is not valid for the server name, check server_name_override: %v", err)
+	case errors.As(err, &invalid):
+		return fmt.Errorf("the server certificate is invalid (e.g. expired): %v", err)
+	case strings.Contains(err.Error(), "certificate required") || strings.Contains(err.Error(), "bad certificate"):
+		return fmt.Errorf("the server requires a valid client certificate, check client_cert_file and client_key_file: %v", err)
+	case strings.Contains(err.Error(), "first record does not look like a TLS handshake"):
+		return fmt.Errorf("the server does not speak TLS, try --security=insecure: %v", err)
+	}
+	return err
+}
+
+// Diagnose connects to the target phase by phase: name resolution, TCP
+// connect, TLS handshake, HTTP/2 preface and a first admin RPC. The phases
+// after a failed one are not run.
+func Diagnose(ctx context.Context, c config.ServerConfig) []Phase {
+	d := &diagnosis{c: c}
+	connectTimeout, _ := timeouts(c)
+	dialCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	if !d.diagnoseTransport(dialCtx) {
+		return d.phases
+	}
+	d.run("Admin RPC", func() (string, error) {
+		backend, err := Connect(ctx, c)
+		if err != nil {
+			return "", err
+		}
+		defer backend.Close()
+		channels, err := backend.Channels(ctx, 0, 1)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("channelz returned %v channel(s)", len(channels)), nil
+	})
+	return d.phases
+}
+
+// diagnoseTransport runs the phases below gRPC, returning false if any failed
+func (d *diagnosis) diagnoseTransport(ctx context.Context) bool {
+	network, address := dialTarget(d.c.RealAddress)
+	if network == "" {
+		reason := fmt.Sprintf("%v is resolved by gRPC", d.c.RealAddress)
+		for _, name := range []string{"Name resolution", "TCP connect", "TLS handshake", "HTTP/2 preface"} {
+			d.skip(name, reason)
+		}
+		return true
+	}
+	// Name resolution
+	dialAddress := address
+	proxyURL, err := proxyFor(d.c, address)
+	if err != nil {
+		d.run("Name resolution", func() (string, error) { return "", err })
+		return false
+	}
+	switch {
+	case network == "unix":
+		d.skip("Name resolution", "Unix domain socket")
+	case d.c.SSHJump != nil:
+		d.skip("Name resolution", fmt.Sprintf("resolved by SSH bastion %v", d.c.SSHJump.Host))
+	case proxyURL != nil:
+		d.skip("Name resolution", fmt.Sprintf("resolved by proxy %v", proxyURL.Redacted()))
+	default:
+		ok := d.run("Name resolution", func() (string, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return "", fmt.Errorf("invalid address %q, expecting host:port: %v", address, err)
+			}
+			addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve %v: %v", host, err)
+			}
+			dialAddress = net.JoinHostPort(addrs[0], port)
+			return fmt.Sprintf("%v -> %v", host, addrs), nil
+		})
+		if !ok {
+			return false
+		}
+	}
+	// TCP connect
+	var rawConn net.Conn
+	closeTunnel := func() {}
+	defer func() { closeTunnel() }()
+	ok := d.run("TCP connect", func() (string, error) {
+		dialer, closeDialer, err := newDialer(d.c)
+		if err != nil {
+			return "", err
+		}
+		closeTunnel = closeDialer
+		if network == "unix" {
+			dialAddress = address
+		}
+		rawConn, err = dialer(ctx, dialAddress)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("connected to %v", rawConn.RemoteAddr()), nil
+	})
+	if !ok {
+		return false
+	}
+	defer rawConn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		rawConn.SetDeadline(deadline)
+	}
+	// TLS handshake
+	conn := rawConn
+	if d.c.Security == config.TypeTLS || d.c.CredentialFile != "" {
+		ok := d.run("TLS handshake", func() (string, error) {
+			tlsConfig, err := newTLSConfig(d.c)
+			if err != nil {
+				return "", err
+			}
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = defaultServerName(network, address)
+			}
+			tlsConfig.NextProtos = []string{"h2"}
+			tlsConn := tls.Client(rawConn, tlsConfig)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				return "", explainTLSError(err)
+			}
+			state := tlsConn.ConnectionState()
+			detail := fmt.Sprintf("%v, ALPN %q, %v", tls.VersionName(state.Version), state.NegotiatedProtocol, tls.CipherSuiteName(state.CipherSuite))
+			if state.NegotiatedProtocol != "h2" {
+				return detail, fmt.Errorf("the server did not negotiate HTTP/2 via ALPN, which gRPC requires")
+			}
+			conn = tlsConn
+			return detail, nil
+		})
+		if !ok {
+			return false
+		}
+	} else {
+		d.skip("TLS handshake", "insecure mode")
+	}
+	// HTTP/2 preface
+	return d.run("HTTP/2 preface", func() (string, error) {
+		framer := http2.NewFramer(conn, conn)
+		_, writeErr := io.WriteString(conn, http2.ClientPreface)
+		if writeErr == nil {
+			writeErr = framer.WriteSettings()
+		}
+		// Reads even if writing failed, since the server may have sent the
+		// reason of closing the connection, e.g. a TLS alert.
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			if _, ok := conn.(*tls.Conn); ok && strings.Contains(err.Error(), "tls:") {
+				return "", explainTLSError(err)
+			}
+			if writeErr != nil {
+				return "", fmt.Errorf("failed to send the HTTP/2 preface: %v", writeErr)
+			}
+			return "", fmt.Errorf("the server did not respond with HTTP/2 frames, it may not be a gRPC server or may expect TLS: %v", err)
+		}
+		if _, ok := frame.(*http2.SettingsFrame); !ok {
+			return "", fmt.Errorf("expecting SETTINGS from the server, received %v", frame.Header().Type)
+		}
+		return "received SETTINGS", nil
+	})
+}
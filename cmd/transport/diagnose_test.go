@@ -0,0 +1,141 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"google.golang.org/grpc"
+	channelzservice "google.golang.org/grpc/channelz/service"
+)
+
+// startListener starts a TCP server handling each connection with handle,
+// and closing it afterwards
+func startListener(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			handle(conn)
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// phaseStatuses formats the phases as "name: status" for comparison
+func phaseStatuses(phases []Phase) string {
+	var statuses []string
+	for _, phase := range phases {
+		statuses = append(statuses, phase.Name+": "+string(phase.Status))
+	}
+	return strings.Join(statuses, ", ")
+}
+
+func TestDiagnose(t *testing.T) {
+	t.Setenv("NO_PROXY", "*")
+	server := grpc.NewServer()
+	channelzservice.RegisterChannelzServiceToServer(server)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	acceptAndClose := startListener(t, func(conn net.Conn) {})
+	notTLS := startListener(t, func(conn net.Conn) {
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+	})
+	mtls := startMTLSServer(t)
+
+	for _, test := range []struct {
+		name   string
+		config config.ServerConfig
+		want   string
+		// wantErr is in the error of the failing phase, the last one
+		wantErr string
+	}{
+		{
+			name:   "gRPC server",
+			config: config.ServerConfig{RealAddress: listener.Addr().String()},
+			want:   "Name resolution: OK, TCP connect: OK, TLS handshake: SKIPPED, HTTP/2 preface: OK, Admin RPC: OK",
+		},
+		{
+			name:    "nothing listening",
+			config:  config.ServerConfig{RealAddress: closedAddress(t)},
+			want:    "Name resolution: OK, TCP connect: FAILED",
+			wantErr: "connection refused",
+		},
+		{
+			name:    "closing the connections",
+			config:  config.ServerConfig{RealAddress: acceptAndClose},
+			want:    "Name resolution: OK, TCP connect: OK, TLS handshake: SKIPPED, HTTP/2 preface: FAILED",
+			wantErr: "the server did not respond with HTTP/2 frames",
+		},
+		{
+			name:   "closing the connections with TLS",
+			config: config.ServerConfig{RealAddress: acceptAndClose, Security: config.TypeTLS},
+			// EOF or a reset connection
+			want: "Name resolution: OK, TCP connect: OK, TLS handshake: FAILED",
+		},
+		{
+			name:    "not TLS",
+			config:  config.ServerConfig{RealAddress: notTLS, Security: config.TypeTLS},
+			want:    "Name resolution: OK, TCP connect: OK, TLS handshake: FAILED",
+			wantErr: "the server does not speak TLS, try --security=insecure",
+		},
+		{
+			name: "untrusted server certificate",
+			config: config.ServerConfig{
+				RealAddress:        mtls,
+				Security:           config.TypeTLS,
+				CredentialFile:     filepath.Join(testCertsDir, "ca.pem"),
+				ServerNameOverride: "foo.test.example.com",
+			},
+			want:    "Name resolution: OK, TCP connect: OK, TLS handshake: FAILED",
+			wantErr: "the server certificate is not signed by a trusted CA, check credential_file or ca_dir",
+		},
+		{
+			name: "without client certificate",
+			config: config.ServerConfig{
+				RealAddress:        mtls,
+				Security:           config.TypeTLS,
+				CredentialFile:     filepath.Join(testCertsDir, "server_ca.pem"),
+				ServerNameOverride: "foo.test.example.com",
+			},
+			// With TLS 1.3, the client certificate is verified after the
+			// handshake of the client
+			want:    "Name resolution: OK, TCP connect: OK, TLS handshake: OK, HTTP/2 preface: FAILED",
+			wantErr: "the server requires a valid client certificate, check client_cert_file and client_key_file",
+		},
+		{
+			name:   "resolved by gRPC",
+			config: config.ServerConfig{RealAddress: "xds:///my-service", ConnectTimeout: time.Second},
+			want:   "Name resolution: SKIPPED, TCP connect: SKIPPED, TLS handshake: SKIPPED, HTTP/2 preface: SKIPPED, Admin RPC: FAILED",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			phases := Diagnose(context.Background(), test.config)
+			if got := phaseStatuses(phases); got != test.want {
+				t.Fatalf("Diagnose() = %v, want %v", got, test.want)
+			}
+			last := phases[len(phases)-1]
+			if test.wantErr != "" && (last.Err == nil || !strings.Contains(last.Err.Error(), test.wantErr)) {
+				t.Errorf("%v failed with %v, want error containing %q", last.Name, last.Err, test.wantErr)
+			}
+		})
+	}
+}
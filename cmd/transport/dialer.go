@@ -0,0 +1,264 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+)
+
+// proxyDirect disables proxies, including the ones from the environment
+const proxyDirect = "direct"
+
+type dialFunc func(ctx context.Context, address string) (net.Conn, error)
+
+// splitDialAddress returns the network and address to dial for the address
+// passed by gRPC to a custom dialer
+func splitDialAddress(address string) (string, string) {
+	if strings.HasPrefix(address, "unix://") {
+		return "unix", strings.TrimPrefix(address, "unix://")
+	}
+	if strings.HasPrefix(address, "unix:") {
+		return "unix", strings.TrimPrefix(address, "unix:")
+	}
+	if strings.HasPrefix(address, "\x00") {
+		// Abstract Unix domain socket
+		return "unix", "@" + address[1:]
+	}
+	return "tcp", address
+}
+
+// noProxy reports whether the NO_PROXY environment variable exempts address
+// from the proxy. Its entries are "*", IP addresses, CIDR ranges, or domain
+// names also matching their subdomains, optionally with a port.
+func noProxy(address string) bool {
+	entries := os.Getenv("NO_PROXY")
+	if entries == "" {
+		entries = os.Getenv("no_proxy")
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, ""
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(entries, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyFor returns the proxy to connect to address, or nil if the address is
+// dialed directly. The proxy configured for the server overrides the
+// HTTPS_PROXY environment variable, and NO_PROXY still exempts its hosts.
+func proxyFor(c config.ServerConfig, address string) (*url.URL, error) {
+	switch c.Proxy {
+	case proxyDirect:
+		return nil, nil
+	case "":
+		return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: "https", Host: address}})
+	default:
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", c.Proxy, err)
+		}
+		if proxyURL.Scheme != "http" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q: expecting http://[user:password@]host:port", c.Proxy)
+		}
+		if address != "" && noProxy(address) {
+			verbose.Debugf("Not using proxy %v for %v, excluded by NO_PROXY", proxyURL.Redacted(), address)
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// bufferedConn returns the bytes read ahead by the bufio.Reader before reading
+// from the underlying connection
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// tunnelAddr is the remote address of a connection through a proxy or an SSH
+// bastion: the address the tunnel leads to, labelled with the tunnel, rather
+// than the address of the tunnel itself
+type tunnelAddr struct {
+	network string
+	address string
+	// via is the tunnel, e.g. "proxy localhost:3128"
+	via string
+}
+
+func (a tunnelAddr) Network() string {
+	return a.network
+}
+
+func (a tunnelAddr) String() string {
+	return fmt.Sprintf("%v via %v", a.address, a.via)
+}
+
+// tunnelConn is a connection through a proxy or an SSH bastion
+type tunnelConn struct {
+	net.Conn
+	addr tunnelAddr
+}
+
+func (c *tunnelConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// proxyError is a refusal of the proxy, e.g. 407 Proxy Authentication
+// Required, or a proxy refusing connections. It isn't temporary, so gRPC fails to connect right away instead of
+// retrying until the connect timeout.
+type proxyError struct {
+	err error
+}
+
+func (e *proxyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *proxyError) Unwrap() error {
+	return e.err
+}
+
+// Temporary is checked by grpc.FailOnNonTempDialError
+func (e *proxyError) Temporary() bool {
+	return false
+}
+
+// dialProxy establishes a tunnel to address through an HTTP CONNECT proxy
+func dialProxy(ctx context.Context, proxyURL *url.URL, address string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, &proxyError{fmt.Errorf("failed to dial proxy %v: %v", proxyURL.Host, err)}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: address},
+		Header: map[string][]string{"User-Agent": {"grpcdebug"}},
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to proxy %v: %v", proxyURL.Host, err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from proxy %v: %v", proxyURL.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		reason := strings.ToLower(http.StatusText(resp.StatusCode))
+		if reason == "" {
+			reason = resp.Status
+		}
+		return nil, &proxyError{fmt.Errorf("proxy %v refused to CONNECT to %v: %v (%v)", proxyURL.Host, address, reason, resp.StatusCode)}
+	}
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// newDialer returns the dialer connecting to the target as configured by c,
+// and the function closing the SSH tunnel it dials through, if any, once the
+// connections are closed
+func newDialer(c config.ServerConfig) (dialFunc, func(), error) {
+	if c.SSHJump != nil {
+		if c.Proxy != "" && c.Proxy != proxyDirect {
+			return nil, nil, fmt.Errorf("proxy and ssh_jump cannot be used together")
+		}
+		tunnel, err := newSSHTunnel(c.SSHJump)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(ctx context.Context, address string) (net.Conn, error) {
+			network, address := splitDialAddress(address)
+			conn, err := tunnel.dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			return &tunnelConn{conn, tunnelAddr{network, address, "SSH bastion " + tunnel.host}}, nil
+		}, tunnel.Close, nil
+	}
+	// Validates the proxy ahead of dialing
+	if _, err := proxyFor(c, ""); err != nil {
+		return nil, nil, err
+	}
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		network, address := splitDialAddress(address)
+		if network == "tcp" {
+			proxyURL, err := proxyFor(c, address)
+			if err != nil {
+				return nil, err
+			}
+			if proxyURL != nil {
+				verbose.Debugf("Dialing %v via HTTP CONNECT proxy %v", address, proxyURL.Redacted())
+				conn, err := dialProxy(ctx, proxyURL, address)
+				if err != nil {
+					return nil, err
+				}
+				return &tunnelConn{conn, tunnelAddr{network, address, "proxy " + proxyURL.Host}}, nil
+			}
+		}
+		verbose.Debugf("Dialing %v:%v directly", network, address)
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}, func() {}, nil
+}
@@ -0,0 +1,220 @@
+package transport
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+)
+
+// testProxy is an HTTP CONNECT proxy recording the tunnels it establishes
+type testProxy struct {
+	// auth is the user:password required, if any
+	auth string
+	mu   sync.Mutex
+	// connects are the addresses CONNECT was requested to
+	connects []string
+	// authorizations are the Proxy-Authorization headers received
+	authorizations []string
+}
+
+func (p *testProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	p.connects = append(p.connects, req.Host)
+	p.authorizations = append(p.authorizations, req.Header.Get("Proxy-Authorization"))
+	p.mu.Unlock()
+	if req.Method != http.MethodConnect {
+		http.Error(rw, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if p.auth != "" && req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(p.auth)) {
+		http.Error(rw, "invalid proxy credentials", http.StatusProxyAuthRequired)
+		return
+	}
+	upstream, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	downstream, buffered, err := rw.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	fmt.Fprint(downstream, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go func() {
+		io.Copy(upstream, buffered)
+		upstream.Close()
+	}()
+	go func() {
+		io.Copy(downstream, upstream)
+		downstream.Close()
+	}()
+}
+
+func (p *testProxy) requests() ([]string, []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.connects...), append([]string(nil), p.authorizations...)
+}
+
+// startProxy serves a CONNECT proxy requiring auth, if not empty, and returns
+// it with its address
+func startProxy(t *testing.T, auth string) (*testProxy, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	proxy := &testProxy{auth: auth}
+	server := &http.Server{Handler: proxy}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return proxy, listener.Addr().String()
+}
+
+// closedAddress returns an address nothing listens on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	listener.Close()
+	return listener.Addr().String()
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "")
+	target := startHealthServer(t)
+	proxy, proxyAddress := startProxy(t, "user:password")
+	backend, err := Connect(context.Background(), config.ServerConfig{
+		RealAddress: target,
+		Proxy:       "http://user:password@" + proxyAddress,
+	})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	defer backend.Close()
+	status, err := backend.GetHealthStatus(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetHealthStatus() failed: %v", err)
+	}
+	if status != "SERVING" {
+		t.Errorf("GetHealthStatus() = %v, want SERVING", status)
+	}
+	connects, authorizations := proxy.requests()
+	if len(connects) != 1 || connects[0] != target {
+		t.Errorf("proxy got CONNECT to %v, want [%v]", connects, target)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:password"))
+	if len(authorizations) != 1 || authorizations[0] != wantAuth {
+		t.Errorf("proxy got Proxy-Authorization %q, want %q", authorizations, wantAuth)
+	}
+}
+
+func TestDialProxyFailsFast(t *testing.T) {
+	t.Setenv("NO_PROXY", "")
+	_, authProxy := startProxy(t, "user:password")
+	for _, test := range []struct {
+		name    string
+		proxy   string
+		wantErr string
+	}{
+		{
+			name:    "authentication required",
+			proxy:   "http://user:wrong@" + authProxy,
+			wantErr: "refused to CONNECT",
+		},
+		{
+			name:    "missing credentials",
+			proxy:   "http://" + authProxy,
+			wantErr: "proxy authentication required (407)",
+		},
+		{
+			name:    "refused connection",
+			proxy:   "http://" + closedAddress(t),
+			wantErr: "failed to dial proxy",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := config.ServerConfig{RealAddress: startHealthServer(t), Proxy: test.proxy, ConnectTimeout: 10 * time.Second}
+			dialer, _, err := newDialer(c)
+			if err != nil {
+				t.Fatalf("newDialer() failed: %v", err)
+			}
+			_, err = dialer(context.Background(), c.RealAddress)
+			var proxyErr *proxyError
+			if !errors.As(err, &proxyErr) {
+				t.Fatalf("dialer() = %v, want a proxyError", err)
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("dialer() = %v, want error containing %q", err, test.wantErr)
+			}
+
+			start := time.Now()
+			_, err = Connect(context.Background(), c)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Connect() = %v, want error containing %q", err, test.wantErr)
+			}
+			// Not retried until the connect timeout
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Connect() failed after %v, want it to fail fast", elapsed)
+			}
+		})
+	}
+}
+
+func TestProxyNoProxy(t *testing.T) {
+	target := startHealthServer(t)
+	proxy, proxyAddress := startProxy(t, "")
+	t.Setenv("NO_PROXY", "example.com,127.0.0.1")
+	backend, err := Connect(context.Background(), config.ServerConfig{RealAddress: target, Proxy: "http://" + proxyAddress})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	backend.Close()
+	if connects, _ := proxy.requests(); len(connects) != 0 {
+		t.Errorf("proxy got CONNECT to %v, want the target excluded by NO_PROXY dialed directly", connects)
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	for _, test := range []struct {
+		noProxy string
+		address string
+		want    bool
+	}{
+		{"", "example.com:443", false},
+		{"*", "example.com:443", true},
+		{"example.com", "example.com:443", true},
+		{"example.com", "api.example.com:443", true},
+		{".example.com", "api.example.com:443", true},
+		{"*.example.com", "api.example.com:443", true},
+		{"example.com", "notexample.com:443", false},
+		{"example.com:443", "example.com:443", true},
+		{"example.com:8443", "example.com:443", false},
+		{"other.com, Example.COM", "example.com:443", true},
+		{"10.0.0.0/8", "10.1.2.3:50051", true},
+		{"10.0.0.0/8", "192.168.0.1:50051", false},
+		{"10.1.2.3", "10.1.2.3:50051", true},
+		{"::1", "[::1]:50051", true},
+		{"10.1.2.3", "example.com:443", false},
+	} {
+		t.Run(fmt.Sprintf("%v/%v", test.noProxy, test.address), func(t *testing.T) {
+			t.Setenv("NO_PROXY", test.noProxy)
+			if got := noProxy(test.address); got != test.want {
+				t.Errorf("noProxy(%q) with NO_PROXY=%q = %v, want %v", test.address, test.noProxy, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The classes of admin RPC failures, matched with errors.Is
+var (
+	// ErrServiceNotRegistered means the admin service is not registered on
+	// the target
+	ErrServiceNotRegistered = errors.New("service is not registered on this target")
+	// ErrNotFound means the requested object does not exist (anymore)
+	ErrNotFound = errors.New("not found")
+	// ErrAccessDenied means the target rejected the credentials
+	ErrAccessDenied = errors.New("access denied")
+	// ErrUnavailable means the target could not be reached in time
+	ErrUnavailable = errors.New("target unavailable")
+	// ErrResponseTooLarge means the response exceeded the max receive size
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+const adminServicesHint = "register the admin services on the server, see https://github.com/grpc-ecosystem/grpcdebug#admin-services"
+
+const compressionHint = "drop --compression or the compression setting"
+
+const maxReceiveSizeHint = "raise the limit with --max_receive_size or the max_receive_size setting, e.g. --max_receive_size=64MiB"
+
+// RPCError is a failed admin RPC
+type RPCError struct {
+	// Service is the admin service called, e.g. "channelz"
+	Service string
+	// Object describes what was fetched, e.g. "channel (id=1)"
+	Object string
+	// Err is the gRPC status error returned by the RPC
+	Err error
+}
+
+func newRPCError(service, object string, err error) error {
+	return &RPCError{Service: service, Object: object, Err: err}
+}
+
+// Code returns the gRPC status code of the failure
+func (e *RPCError) Code() codes.Code {
+	return status.Code(e.Err)
+}
+
+// unsupportedCompression reports whether the target failed to decompress the
+// request, which is reported as UNIMPLEMENTED
+func (e *RPCError) unsupportedCompression() bool {
+	return e.Code() == codes.Unimplemented && strings.Contains(status.Convert(e.Err).Message(), "Decompressor is not installed")
+}
+
+// tooLarge reports whether the response was rejected for exceeding the max
+// receive size
+func (e *RPCError) tooLarge() bool {
+	return e.Code() == codes.ResourceExhausted && strings.Contains(status.Convert(e.Err).Message(), "larger than max")
+}
+
+func (e *RPCError) Error() string {
+	message := status.Convert(e.Err).Message()
+	if e.unsupportedCompression() {
+		return fmt.Sprintf("the target does not support the compression of the request: %v (hint: %v)", message, compressionHint)
+	}
+	if e.tooLarge() {
+		return fmt.Sprintf("%v exceeds the max receive size: %v (hint: %v)", e.Object, message, maxReceiveSizeHint)
+	}
+	switch e.Code() {
+	case codes.Unimplemented:
+		return fmt.Sprintf("%v service is not registered on this target (hint: %v)", e.Service, adminServicesHint)
+	case codes.NotFound:
+		return fmt.Sprintf("%v not found", e.Object)
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return fmt.Sprintf("access denied fetching %v: %v", e.Object, message)
+	case codes.Unavailable:
+		return fmt.Sprintf("target unavailable fetching %v: %v", e.Object, message)
+	case codes.DeadlineExceeded:
+		return fmt.Sprintf("timed out fetching %v", e.Object)
+	default:
+		return fmt.Sprintf("failed to fetch %v: %v", e.Object, e.Err)
+	}
+}
+
+// Is reports whether the failure belongs to the given class of errors
+func (e *RPCError) Is(target error) bool {
+	if e.unsupportedCompression() {
+		return false
+	}
+	if e.tooLarge() {
+		return target == ErrResponseTooLarge
+	}
+	switch e.Code() {
+	case codes.Unimplemented:
+		return target == ErrServiceNotRegistered
+	case codes.NotFound:
+		return target == ErrNotFound
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return target == ErrAccessDenied
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return target == ErrUnavailable
+	}
+	return false
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
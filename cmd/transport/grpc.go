@@ -2,124 +2,310 @@ package transport
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	csdspb "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
 	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
 	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
 	"google.golang.org/grpc"
 	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
-var conn *grpc.ClientConn
-var channelzClient zpb.ChannelzClient
-var csdsClient csdspb.ClientStatusDiscoveryServiceClient
-var healthClient healthpb.HealthClient
+const defaultConnectTimeout = time.Second * 5
+const defaultRPCTimeout = time.Second * 15
 
-const connectionTimeout = time.Second * 5
-const rpcTimeout = time.Second * 15
+// adminConn is the connection the admin RPCs are sent over
+type adminConn interface {
+	grpc.ClientConnInterface
+	Close() error
+}
+
+// clientConn is a connection to a target, which closes the SSH tunnel it goes
+// through along with it
+type clientConn struct {
+	*grpc.ClientConn
+	closeTunnel func()
+}
+
+func (c *clientConn) Close() error {
+	err := c.ClientConn.Close()
+	c.closeTunnel()
+	return err
+}
 
-// Connect connects to the service at address and creates stubs
-func Connect(c config.ServerConfig) {
+// grpcBackend is the Backend querying the admin services of a live target
+type grpcBackend struct {
+	conn           adminConn
+	channelzClient zpb.ChannelzClient
+	csdsClient     csdspb.ClientStatusDiscoveryServiceClient
+	healthClient   healthpb.HealthClient
+	// rpcTimeout is the deadline of every admin RPC
+	rpcTimeout time.Duration
+}
+
+// timeouts returns the connect and RPC timeouts of c, or the defaults if unset
+func timeouts(c config.ServerConfig) (time.Duration, time.Duration) {
+	connectTimeout, rpcTimeout := defaultConnectTimeout, defaultRPCTimeout
+	if c.ConnectTimeout > 0 {
+		connectTimeout = c.ConnectTimeout
+	}
+	if c.RPCTimeout > 0 {
+		rpcTimeout = c.RPCTimeout
+	}
+	return connectTimeout, rpcTimeout
+}
+
+// appendCertsFromFile adds the PEM encoded certificates in path to pool
+func appendCertsFromFile(pool *x509.CertPool, path string) error {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in %v", path)
+	}
+	return nil
+}
+
+// newTLSConfig builds the TLS config described by the security settings of c
+func newTLSConfig(c config.ServerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: c.ServerNameOverride}
+	if c.CredentialFile != "" || c.CADir != "" {
+		tlsConfig.RootCAs = x509.NewCertPool()
+	}
+	if c.CredentialFile != "" {
+		if err := appendCertsFromFile(tlsConfig.RootCAs, c.CredentialFile); err != nil {
+			return nil, fmt.Errorf("failed to load credential file: %v", err)
+		}
+	}
+	if c.CADir != "" {
+		files, err := os.ReadDir(c.CADir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA directory: %v", err)
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			// Files without certificates (e.g. READMEs) are skipped
+			if err := appendCertsFromFile(tlsConfig.RootCAs, filepath.Join(c.CADir, file.Name())); err != nil {
+				verbose.Debugf("skipping %v in CA directory: %v", file.Name(), err)
+			}
+		}
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return nil, fmt.Errorf("client certificate and client key must be specified together")
+	}
+	if c.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client key pair: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Connect connects to the service at address, and returns the Backend
+// querying its admin services
+func Connect(ctx context.Context, c config.ServerConfig) (Backend, error) {
+	conn, err := dial(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	_, rpcTimeout := timeouts(c)
+	return newGRPCBackend(conn, rpcTimeout), nil
+}
+
+func newGRPCBackend(conn adminConn, rpcTimeout time.Duration) *grpcBackend {
+	return &grpcBackend{
+		conn:           conn,
+		channelzClient: zpb.NewChannelzClient(conn),
+		csdsClient:     csdspb.NewClientStatusDiscoveryServiceClient(conn),
+		healthClient:   healthpb.NewHealthClient(conn),
+		rpcTimeout:     rpcTimeout,
+	}
+}
+
+// dial connects to the target with the settings of c, and waits for the
+// connection to be READY
+func dial(ctx context.Context, c config.ServerConfig) (*clientConn, error) {
 	verbose.Debugf("Connecting with %v", c)
-	var err error
 	var credOption grpc.DialOption
-	if c.CredentialFile != "" {
-		cred, err := credentials.NewClientTLSFromFile(c.CredentialFile, c.ServerNameOverride)
+	secure := c.Security == config.TypeTLS || c.CredentialFile != ""
+	if secure {
+		tlsConfig, err := newTLSConfig(c)
 		if err != nil {
-			log.Fatalf("failed to create credential: %v", err)
+			return nil, fmt.Errorf("failed to create credential: %v", err)
 		}
-		credOption = grpc.WithTransportCredentials(cred)
+		credOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
 	} else {
 		credOption = grpc.WithInsecure()
 	}
+	dialOptions := []grpc.DialOption{
+		credOption,
+		grpc.WithBlock(),
+		// Reports why connecting failed, e.g. the refusal of a proxy, rather
+		// than "context deadline exceeded"
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithReturnConnectionError(),
+	}
+	perRPCCreds, err := newPerRPCCredentials(c, secure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create per-RPC credential: %v", err)
+	}
+	for _, cred := range perRPCCreds {
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(cred))
+	}
+	if c.XdsBootstrap != "" {
+		xdsResolver, err := newXdsResolver(c.XdsBootstrap)
+		if err != nil {
+			return nil, err
+		}
+		dialOptions = append(dialOptions, grpc.WithResolvers(xdsResolver))
+	}
+	callOptions, err := newCallOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	dialOptions = append(dialOptions, grpc.WithDefaultCallOptions(callOptions...))
+	dialOptions = append(dialOptions, grpc.WithChainUnaryInterceptor(logPeerInterceptor))
+	dialer, closeTunnel, err := newDialer(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dialer: %v", err)
+	}
+	dialOptions = append(dialOptions, grpc.WithContextDialer(dialer))
 	// Dial, wait for READY, with a timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	connectTimeout, _ := timeouts(c)
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
 	defer cancel()
-	conn, err = grpc.DialContext(ctx, c.RealAddress, credOption, grpc.WithBlock())
+	conn, err := grpc.DialContext(ctx, c.RealAddress, dialOptions...)
 	if err != nil {
-		log.Fatalf("failed to connect: %v", err)
+		closeTunnel()
 	}
-	channelzClient = zpb.NewChannelzClient(conn)
-	csdsClient = csdspb.NewClientStatusDiscoveryServiceClient(conn)
-	healthClient = healthpb.NewHealthClient(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	return &clientConn{ClientConn: conn, closeTunnel: closeTunnel}, nil
+}
+
+// newCallOptions returns the max receive size and compression options of the
+// admin RPCs
+func newCallOptions(c config.ServerConfig) ([]grpc.CallOption, error) {
+	var callOptions []grpc.CallOption
+	if c.MaxReceiveSize != "" {
+		size, err := humanize.ParseBytes(c.MaxReceiveSize)
+		if err != nil || size == 0 || size > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid max receive size %q, expecting a size like 64MiB", c.MaxReceiveSize)
+		}
+		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(int(size)))
+	}
+	switch c.Compression {
+	case "":
+	case gzip.Name:
+		callOptions = append(callOptions, grpc.UseCompressor(gzip.Name))
+	default:
+		return nil, fmt.Errorf("unsupported compression %q, expecting %q", c.Compression, gzip.Name)
+	}
+	return callOptions, nil
+}
+
+// logPeerInterceptor reports the backend each admin RPC landed on, which may
+// vary if the target resolves to multiple backends. Behind a proxy or an SSH
+// bastion, it's the address the tunnel leads to, labelled with the tunnel.
+func logPeerInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var p peer.Peer
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+	if p.Addr != nil {
+		verbose.Debugf("%v landed on %v", method, p.Addr)
+	}
+	return err
 }
 
 // Channels returns all available channels
-func Channels(startID, maxResults int64) []*zpb.Channel {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) Channels(ctx context.Context, startID, maxResults int64) ([]*zpb.Channel, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	channels, err := channelzClient.GetTopChannels(ctx, &zpb.GetTopChannelsRequest{StartChannelId: startID, MaxResults: maxResults})
+	channels, err := b.channelzClient.GetTopChannels(ctx, &zpb.GetTopChannelsRequest{StartChannelId: startID, MaxResults: maxResults})
 	if err != nil {
-		log.Fatalf("failed to fetch top channels: %v", err)
+		return nil, newRPCError("channelz", "top channels", err)
 	}
-	return channels.Channel
+	return channels.Channel, nil
 }
 
 // Channel returns the channel with given channel ID
-func Channel(channelID int64) *zpb.Channel {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) Channel(ctx context.Context, channelID int64) (*zpb.Channel, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	channel, err := channelzClient.GetChannel(ctx, &zpb.GetChannelRequest{ChannelId: channelID})
+	channel, err := b.channelzClient.GetChannel(ctx, &zpb.GetChannelRequest{ChannelId: channelID})
 	if err != nil {
-		log.Fatalf("failed to fetch channel id=%v: %v", channelID, err)
+		return nil, newRPCError("channelz", fmt.Sprintf("channel (id=%v)", channelID), err)
 	}
-	return channel.Channel
+	return channel.Channel, nil
 }
 
 // Subchannel returns the queried subchannel
-func Subchannel(subchannelID int64) *zpb.Subchannel {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) Subchannel(ctx context.Context, subchannelID int64) (*zpb.Subchannel, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	subchannel, err := channelzClient.GetSubchannel(ctx, &zpb.GetSubchannelRequest{SubchannelId: subchannelID})
+	subchannel, err := b.channelzClient.GetSubchannel(ctx, &zpb.GetSubchannelRequest{SubchannelId: subchannelID})
 	if err != nil {
-		log.Fatalf("failed to fetch subchannel (id=%v): %v", subchannelID, err)
+		return nil, newRPCError("channelz", fmt.Sprintf("subchannel (id=%v)", subchannelID), err)
 	}
-	return subchannel.Subchannel
+	return subchannel.Subchannel, nil
 }
 
 // Servers returns all available servers
-func Servers(startID, maxResults int64) []*zpb.Server {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) Servers(ctx context.Context, startID, maxResults int64) ([]*zpb.Server, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	servers, err := channelzClient.GetServers(ctx, &zpb.GetServersRequest{StartServerId: startID, MaxResults: maxResults})
+	servers, err := b.channelzClient.GetServers(ctx, &zpb.GetServersRequest{StartServerId: startID, MaxResults: maxResults})
 	if err != nil {
-		log.Fatalf("failed to fetch servers: %v", err)
+		return nil, newRPCError("channelz", "servers", err)
 	}
-	return servers.Server
+	return servers.Server, nil
 }
 
 // Server returns a server
-func Server(serverID int64) *zpb.Server {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) Server(ctx context.Context, serverID int64) (*zpb.Server, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	server, err := channelzClient.GetServer(ctx, &zpb.GetServerRequest{ServerId: serverID})
+	server, err := b.channelzClient.GetServer(ctx, &zpb.GetServerRequest{ServerId: serverID})
 	if err != nil {
-		log.Fatalf("failed to fetch server (id=%v): %v", serverID, err)
+		return nil, newRPCError("channelz", fmt.Sprintf("server (id=%v)", serverID), err)
 	}
-	return server.Server
+	return server.Server, nil
 }
 
 // Socket returns a socket
-func Socket(socketID int64) *zpb.Socket {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) Socket(ctx context.Context, socketID int64) (*zpb.Socket, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	socket, err := channelzClient.GetSocket(ctx, &zpb.GetSocketRequest{SocketId: socketID})
+	socket, err := b.channelzClient.GetSocket(ctx, &zpb.GetSocketRequest{SocketId: socketID})
 	if err != nil {
-		log.Fatalf("failed to fetch socket (id=%v): %v", socketID, err)
+		return nil, newRPCError("channelz", fmt.Sprintf("socket (id=%v)", socketID), err)
 	}
-	return socket.Socket
+	return socket.Socket, nil
 }
 
-// ServerSocket returns all sockets of this server
-func ServerSocket(serverID, startID, maxResults int64) []*zpb.Socket {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+// ServerSockets returns the references to the sockets of this server
+func (b *grpcBackend) ServerSockets(ctx context.Context, serverID, startID, maxResults int64) ([]*zpb.SocketRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	var s []*zpb.Socket
-	serverSocketResp, err := channelzClient.GetServerSockets(
+	serverSocketResp, err := b.channelzClient.GetServerSockets(
 		ctx,
 		&zpb.GetServerSocketsRequest{
 			ServerId:      serverID,
@@ -128,33 +314,39 @@ func ServerSocket(serverID, startID, maxResults int64) []*zpb.Socket {
 		},
 	)
 	if err != nil {
-		log.Fatalf("failed to fetch server sockets (id=%v): %v", serverID, err)
+		return nil, newRPCError("channelz", fmt.Sprintf("server sockets (id=%v)", serverID), err)
 	}
-	for _, socketRef := range serverSocketResp.SocketRef {
-		s = append(s, Socket(socketRef.SocketId))
-	}
-	return s
+	return serverSocketResp.SocketRef, nil
 }
 
 // FetchClientStatus fetches the xDS resources status
-func FetchClientStatus() *csdspb.ClientStatusResponse {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) FetchClientStatus(ctx context.Context) (*csdspb.ClientStatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	resp, err := csdsClient.FetchClientStatus(ctx, &csdspb.ClientStatusRequest{})
+	resp, err := b.csdsClient.FetchClientStatus(ctx, &csdspb.ClientStatusRequest{})
 	if err != nil {
-		log.Fatalf("failed to fetch xds config: %v", err)
+		return nil, newRPCError("CSDS", "xds config", err)
 	}
-	return resp
+	return resp, nil
 }
 
 // GetHealthStatus fetches the health checking status of the service from peer
-func GetHealthStatus(service string) string {
-	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+func (b *grpcBackend) GetHealthStatus(ctx context.Context, service string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.rpcTimeout)
 	defer cancel()
-	resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
-	if err != nil {
+	resp, err := b.healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if status.Code(err) == codes.NotFound {
+		// Services unknown to the peer are reported as NOT_FOUND
 		verbose.Debugf("failed to fetch health status for \"%s\": %v", service, err)
-		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN.String()
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN.String(), nil
 	}
-	return resp.Status.String()
+	if err != nil {
+		return "", newRPCError("health", fmt.Sprintf("health status of %q", service), err)
+	}
+	return resp.Status.String(), nil
+}
+
+// Close closes the connection to the target
+func (b *grpcBackend) Close() error {
+	return b.conn.Close()
 }
@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/testdata"
+)
+
+// testCertsDir has the certificates of the mock test server
+const testCertsDir = "../../internal/testing"
+
+// startMTLSServer starts a health server requiring mutual TLS, like the mTLS
+// admin server of the mock test server
+func startMTLSServer(t *testing.T) string {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(testdata.Path("x509/server1_cert.pem"), testdata.Path("x509/server1_key.pem"))
+	if err != nil {
+		t.Fatalf("failed to load key pair: %v", err)
+	}
+	clientCA, err := os.ReadFile(testdata.Path("x509/client_ca_cert.pem"))
+	if err != nil {
+		t.Fatalf("failed to load client CA: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCA)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// checkHealth connects with c and returns the overall health, or the error
+// of connecting or of the RPC
+func checkHealth(c config.ServerConfig) (string, error) {
+	backend, err := Connect(context.Background(), c)
+	if err != nil {
+		return "", err
+	}
+	defer backend.Close()
+	return backend.GetHealthStatus(context.Background(), "")
+}
+
+func TestMutualTLS(t *testing.T) {
+	address := startMTLSServer(t)
+	caDir := t.TempDir()
+	serverCA, err := os.ReadFile(filepath.Join(testCertsDir, "server_ca.pem"))
+	if err != nil {
+		t.Fatalf("failed to read server CA: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(caDir, "server_ca.pem"), serverCA, 0644); err != nil {
+		t.Fatalf("failed to write server CA: %v", err)
+	}
+	// All the files of the directory are trusted, even if they don't sign the
+	// server certificate, like ca.pem
+	ca, err := os.ReadFile(filepath.Join(testCertsDir, "ca.pem"))
+	if err != nil {
+		t.Fatalf("failed to read CA: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(caDir, "ca.pem"), ca, 0644); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+	base := config.ServerConfig{
+		RealAddress:        address,
+		Security:           config.TypeTLS,
+		ServerNameOverride: "foo.test.example.com",
+		ClientCertFile:     filepath.Join(testCertsDir, "client_cert.pem"),
+		ClientKeyFile:      filepath.Join(testCertsDir, "client_key.pem"),
+		// The failures are reported once the connection times out
+		ConnectTimeout: time.Second,
+		RPCTimeout:     time.Second,
+	}
+	for _, test := range []struct {
+		name    string
+		config  func(c *config.ServerConfig)
+		wantErr string
+	}{
+		{
+			name:   "credential file",
+			config: func(c *config.ServerConfig) { c.CredentialFile = filepath.Join(testCertsDir, "server_ca.pem") },
+		},
+		{
+			name:   "CA directory",
+			config: func(c *config.ServerConfig) { c.CADir = caDir },
+		},
+		{
+			name: "without client certificate",
+			config: func(c *config.ServerConfig) {
+				c.CADir = caDir
+				c.ClientCertFile, c.ClientKeyFile = "", ""
+			},
+			wantErr: "certificate required",
+		},
+		{
+			name: "untrusted server",
+			config: func(c *config.ServerConfig) {
+				c.CredentialFile = filepath.Join(testCertsDir, "ca.pem")
+			},
+			wantErr: "certificate signed by unknown authority",
+		},
+		{
+			name: "client certificate without key",
+			config: func(c *config.ServerConfig) {
+				c.CADir = caDir
+				c.ClientKeyFile = ""
+			},
+			wantErr: "client certificate and client key must be specified together",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := base
+			test.config(&c)
+			status, err := checkHealth(c)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("checkHealth() = %q, %v, want error containing %q", status, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil || status != "SERVING" {
+				t.Errorf("checkHealth() = %q, %v, want SERVING", status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+)
+
+// TLSCheck is the outcome of one verification step of the peer certificate
+type TLSCheck struct {
+	Name string
+	// Err is nil if the check passed
+	Err error
+}
+
+// TLSInspection is the TLS session and the certificate chain presented by the
+// target
+type TLSInspection struct {
+	ServerName  string
+	Version     string
+	CipherSuite string
+	ALPN        string
+	// PeerCertificates is the chain presented by the peer, leaf first
+	PeerCertificates []*x509.Certificate
+	Checks           []TLSCheck
+}
+
+// InspectTLS performs a TLS handshake with the target using the security
+// settings of c. The peer chain is returned even if it doesn't verify, and the
+// verification steps are reported as Checks.
+func InspectTLS(ctx context.Context, c config.ServerConfig) (*TLSInspection, error) {
+	network, address := dialTarget(c.RealAddress)
+	if network == "" {
+		return nil, fmt.Errorf("inspecting TLS of %v is not supported, since it's resolved by gRPC", c.RealAddress)
+	}
+	tlsConfig, err := newTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = defaultServerName(network, address)
+	}
+	connectTimeout, _ := timeouts(c)
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	dialer, closeTunnel, err := newDialer(c)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTunnel()
+	rawConn, err := dialer(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rawConn.Close()
+	// Verification is done below step by step, so every failed step can be
+	// reported instead of the first one.
+	handshakeConfig := tlsConfig.Clone()
+	handshakeConfig.InsecureSkipVerify = true
+	handshakeConfig.NextProtos = []string{"h2"}
+	var clientCertRequested bool
+	handshakeConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		clientCertRequested = true
+		if len(tlsConfig.Certificates) > 0 {
+			return &tlsConfig.Certificates[0], nil
+		}
+		return &tls.Certificate{}, nil
+	}
+	tlsConn := tls.Client(rawConn, handshakeConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, explainTLSError(err)
+	}
+	state := tlsConn.ConnectionState()
+	inspection := &TLSInspection{
+		ServerName:       tlsConfig.ServerName,
+		Version:          tls.VersionName(state.Version),
+		CipherSuite:      tls.CipherSuiteName(state.CipherSuite),
+		ALPN:             state.NegotiatedProtocol,
+		PeerCertificates: state.PeerCertificates,
+	}
+	if len(state.PeerCertificates) == 0 {
+		return inspection, fmt.Errorf("the server presented no certificate")
+	}
+	leaf := state.PeerCertificates[0]
+	now := time.Now()
+	var validity error
+	if now.Before(leaf.NotBefore) {
+		validity = fmt.Errorf("not valid before %v", leaf.NotBefore)
+	} else if now.After(leaf.NotAfter) {
+		validity = fmt.Errorf("expired at %v", leaf.NotAfter)
+	}
+	inspection.Checks = append(inspection.Checks, TLSCheck{Name: "Validity period", Err: validity})
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	trustName := "Trusted by system roots"
+	if c.CredentialFile != "" || c.CADir != "" {
+		trustName = "Trusted by credential_file/ca_dir"
+	}
+	verifyOptions := x509.VerifyOptions{Roots: tlsConfig.RootCAs, Intermediates: intermediates}
+	if validity != nil {
+		// Validity is reported above, the chain is verified within the
+		// validity period of the leaf
+		verifyOptions.CurrentTime = leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2)
+	}
+	_, trustErr := leaf.Verify(verifyOptions)
+	inspection.Checks = append(inspection.Checks, TLSCheck{Name: trustName, Err: trustErr})
+	inspection.Checks = append(inspection.Checks, TLSCheck{
+		Name: fmt.Sprintf("Server name %q", tlsConfig.ServerName),
+		Err:  leaf.VerifyHostname(tlsConfig.ServerName),
+	})
+	if clientCertRequested {
+		var clientCertErr error
+		if len(tlsConfig.Certificates) == 0 {
+			clientCertErr = fmt.Errorf("the server requests a client certificate, check client_cert_file and client_key_file")
+		}
+		inspection.Checks = append(inspection.Checks, TLSCheck{Name: "Client certificate", Err: clientCertErr})
+	}
+	if state.NegotiatedProtocol != "h2" {
+		inspection.Checks = append(inspection.Checks, TLSCheck{Name: "ALPN", Err: fmt.Errorf("the server did not negotiate h2, which gRPC requires")})
+	}
+	return inspection, nil
+}
@@ -0,0 +1,185 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+)
+
+// testCert is a generated certificate and its key
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert generates a certificate from template, signed by parent, or
+// self-signed if parent is nil
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func newTestCA(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	return newTestCert(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: name},
+		// Before the leaves, even the expired ones
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, parent)
+}
+
+// writeCA writes the PEM encoded certificate of the CA, and returns its path
+func writeCA(t *testing.T, ca *testCert) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0644); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+	return file
+}
+
+// startTLSServer starts a server completing TLS handshakes with the chain of
+// the leaf and the intermediate CA
+func startTLSServer(t *testing.T, leaf, intermediate *testCert) string {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.der, intermediate.der},
+			PrivateKey:  leaf.key,
+		}},
+		NextProtos: []string{"h2"},
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestInspectTLS(t *testing.T) {
+	root := newTestCA(t, "test root", nil)
+	intermediate := newTestCA(t, "test intermediate", root)
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/prod/sa/admin")
+	newLeaf := func(notAfter time.Time) *testCert {
+		return newTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "admin"},
+			DNSNames:    []string{"admin.test.example.com"},
+			URIs:        []*url.URL{spiffeID},
+			NotBefore:   time.Now().Add(-2 * time.Hour),
+			NotAfter:    notAfter,
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, intermediate)
+	}
+	valid := startTLSServer(t, newLeaf(time.Now().Add(time.Hour)), intermediate)
+	expired := startTLSServer(t, newLeaf(time.Now().Add(-time.Hour)), intermediate)
+	rootFile := writeCA(t, root)
+	otherCA := writeCA(t, newTestCA(t, "other root", nil))
+
+	for _, test := range []struct {
+		name   string
+		config config.ServerConfig
+		// wantFailed maps the failed checks to their errors
+		wantFailed map[string]string
+	}{
+		{
+			name:   "valid",
+			config: config.ServerConfig{RealAddress: valid, CredentialFile: rootFile, ServerNameOverride: "admin.test.example.com"},
+		},
+		{
+			name:       "expired",
+			config:     config.ServerConfig{RealAddress: expired, CredentialFile: rootFile, ServerNameOverride: "admin.test.example.com"},
+			wantFailed: map[string]string{"Validity period": "expired at"},
+		},
+		{
+			name:       "wrong server name",
+			config:     config.ServerConfig{RealAddress: valid, CredentialFile: rootFile, ServerNameOverride: "other.test.example.com"},
+			wantFailed: map[string]string{`Server name "other.test.example.com"`: "certificate is valid for admin.test.example.com, not other.test.example.com"},
+		},
+		{
+			name:       "untrusted",
+			config:     config.ServerConfig{RealAddress: valid, CredentialFile: otherCA, ServerNameOverride: "admin.test.example.com"},
+			wantFailed: map[string]string{"Trusted by credential_file/ca_dir": "certificate signed by unknown authority"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Security = config.TypeTLS
+			inspection, err := InspectTLS(context.Background(), test.config)
+			if err != nil {
+				t.Fatalf("InspectTLS() failed: %v", err)
+			}
+			if inspection.ALPN != "h2" || inspection.ServerName != test.config.ServerNameOverride {
+				t.Errorf("InspectTLS() = ALPN %q, server name %q, want h2 and %q", inspection.ALPN, inspection.ServerName, test.config.ServerNameOverride)
+			}
+			// The chain is returned even if it doesn't verify
+			chain := inspection.PeerCertificates
+			if len(chain) != 2 || chain[0].Subject.CommonName != "admin" || chain[1].Subject.CommonName != "test intermediate" {
+				t.Fatalf("InspectTLS() returned chain %v, want the leaf and the intermediate", chain)
+			}
+			if uris := chain[0].URIs; len(uris) != 1 || uris[0].String() != spiffeID.String() {
+				t.Errorf("leaf URIs = %v, want the SPIFFE ID %v", uris, spiffeID)
+			}
+			var names []string
+			for _, check := range inspection.Checks {
+				names = append(names, check.Name)
+				want, failed := test.wantFailed[check.Name]
+				switch {
+				case failed && (check.Err == nil || !strings.Contains(check.Err.Error(), want)):
+					t.Errorf("check %q = %v, want error containing %q", check.Name, check.Err, want)
+				case !failed && check.Err != nil:
+					t.Errorf("check %q failed: %v", check.Name, check.Err)
+				}
+			}
+			wantNames := []string{"Validity period", "Trusted by credential_file/ca_dir", `Server name "` + test.config.ServerNameOverride + `"`}
+			if strings.Join(names, ", ") != strings.Join(wantNames, ", ") {
+				t.Errorf("checks = %q, want %q", names, wantNames)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// resolverConn receives the first update of a resolver
+type resolverConn struct {
+	updates chan resolver.State
+	errors  chan error
+}
+
+func (r *resolverConn) UpdateState(state resolver.State) error {
+	select {
+	case r.updates <- state:
+	default:
+	}
+	return nil
+}
+
+func (r *resolverConn) ReportError(err error) {
+	select {
+	case r.errors <- err:
+	default:
+	}
+}
+
+func (r *resolverConn) NewAddress(addresses []resolver.Address) {
+	r.UpdateState(resolver.State{Addresses: addresses})
+}
+
+func (r *resolverConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return &serviceconfig.ParseResult{}
+}
+
+// ResolveBackends resolves the target through the gRPC resolver of its scheme
+// (DNS if none), and returns the addresses of all the backends. Targets
+// without a scheme are resolved through DNS.
+func ResolveBackends(ctx context.Context, c config.ServerConfig) ([]string, error) {
+	target, err := url.Parse(c.RealAddress)
+	if err == nil && target.Scheme == "xds" {
+		// The xDS resolver leaves the backends to the load balancer
+		return resolveXdsBackends(ctx, c)
+	}
+	if err != nil || resolver.Get(target.Scheme) == nil {
+		target, err = url.Parse("dns:///" + c.RealAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %q: %v", c.RealAddress, err)
+		}
+	}
+	switch target.Scheme {
+	case "passthrough", "unix", "unix-abstract":
+		// These targets are the address of a single backend
+		return []string{c.RealAddress}, nil
+	}
+	conn := &resolverConn{updates: make(chan resolver.State, 1), errors: make(chan error, 1)}
+	r, err := resolver.Get(target.Scheme).Build(resolver.Target{URL: *target}, conn, resolver.BuildOptions{DisableServiceConfig: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+	}
+	defer r.Close()
+	connectTimeout, _ := timeouts(c)
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	var state resolver.State
+	select {
+	case state = <-conn.updates:
+	case err := <-conn.errors:
+		return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out resolving %v", c.RealAddress)
+	}
+	var backends []string
+	for _, address := range state.Addresses {
+		backends = append(backends, address.Addr)
+	}
+	for _, endpoint := range state.Endpoints {
+		for _, address := range endpoint.Addresses {
+			backends = append(backends, address.Addr)
+		}
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("%v resolved to no backend addresses", c.RealAddress)
+	}
+	return backends, nil
+}
+
+// BackendConfig returns the config connecting to one backend of the target,
+// which still verifies the certificate against the name of the target
+func BackendConfig(c config.ServerConfig, backend string) config.ServerConfig {
+	if network, address := dialTarget(c.RealAddress); network == "tcp" && c.ServerNameOverride == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			c.ServerNameOverride = host
+		}
+	}
+	c.RealAddress = backend
+	return c
+}
@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// registerManualResolver registers a resolver of the scheme resolving every
+// target to state
+func registerManualResolver(scheme string, state resolver.State) {
+	r := manual.NewBuilderWithScheme(scheme)
+	r.InitialState(state)
+	resolver.Register(r)
+}
+
+func TestResolveBackends(t *testing.T) {
+	registerManualResolver("test-addresses", resolver.State{Addresses: []resolver.Address{
+		{Addr: "10.0.0.1:50051"},
+		{Addr: "10.0.0.2:50051"},
+		{Addr: "10.0.0.3:50051"},
+	}})
+	registerManualResolver("test-endpoints", resolver.State{Endpoints: []resolver.Endpoint{
+		{Addresses: []resolver.Address{{Addr: "10.0.1.1:50051"}}},
+		{Addresses: []resolver.Address{{Addr: "10.0.1.2:50051"}, {Addr: "[fd00::2]:50051"}}},
+	}})
+	registerManualResolver("test-empty", resolver.State{})
+	tests := []struct {
+		target  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			target: "test-addresses:///my-service",
+			want:   []string{"10.0.0.1:50051", "10.0.0.2:50051", "10.0.0.3:50051"},
+		},
+		{
+			target: "test-endpoints:///my-service",
+			want:   []string{"10.0.1.1:50051", "10.0.1.2:50051", "[fd00::2]:50051"},
+		},
+		{
+			target:  "test-empty:///my-service",
+			wantErr: true,
+		},
+		{
+			// A single backend, without resolving
+			target: "passthrough:///my-service.example.com:50051",
+			want:   []string{"passthrough:///my-service.example.com:50051"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			got, err := ResolveBackends(context.Background(), config.ServerConfig{RealAddress: test.target})
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ResolveBackends() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveBackends() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ResolveBackends() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackendConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.ServerConfig
+		want   config.ServerConfig
+	}{
+		{
+			name:   "verifies the name of the target",
+			config: config.ServerConfig{RealAddress: "my-service.example.com:50051", Security: config.TypeTLS},
+			want:   config.ServerConfig{RealAddress: "10.0.0.1:50051", Security: config.TypeTLS, ServerNameOverride: "my-service.example.com"},
+		},
+		{
+			name:   "keeps the server name override",
+			config: config.ServerConfig{RealAddress: "dns:///my-service.example.com:50051", ServerNameOverride: "other.example.com"},
+			want:   config.ServerConfig{RealAddress: "10.0.0.1:50051", ServerNameOverride: "other.example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := BackendConfig(test.config, "10.0.0.1:50051"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("BackendConfig() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
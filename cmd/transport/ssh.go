@@ -0,0 +1,167 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultSSHPort = "22"
+
+// sshTunnel dials connections through an SSH bastion, similar to "ssh -L". The
+// SSH connection is established on the first dial, and shared afterwards until
+// it drops.
+type sshTunnel struct {
+	host         string
+	clientConfig *ssh.ClientConfig
+	// agentConn is the connection to the SSH agent, if the keys come from it
+	agentConn net.Conn
+	mu        sync.Mutex
+	client    *ssh.Client
+	closed    bool
+}
+
+// sshAuthMethod returns the key file's signer if given, otherwise the signers
+// of the SSH agent, along with the connection to the agent
+func sshAuthMethod(keyFile string) (ssh.AuthMethod, net.Conn, error) {
+	if keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read SSH key: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse SSH key: %v", err)
+		}
+		return ssh.PublicKeys(signer), nil, nil
+	}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, fmt.Errorf("neither an SSH key file is specified nor $SSH_AUTH_SOCK is defined")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to SSH agent: %v", err)
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), conn, nil
+}
+
+func newSSHTunnel(c *config.SSHJumpConfig) (*sshTunnel, error) {
+	if c.Host == "" || c.User == "" {
+		return nil, fmt.Errorf("SSH jump host and user must be specified")
+	}
+	host := c.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, defaultSSHPort)
+	}
+	knownHostsFile := c.KnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %v", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %v", err)
+	}
+	auth, agentConn, err := sshAuthMethod(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &sshTunnel{
+		host: host,
+		clientConfig: &ssh.ClientConfig{
+			User:            c.User,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeyCallback,
+		},
+		agentConn: agentConn,
+	}, nil
+}
+
+// sshClient returns the established SSH connection, connecting if needed
+func (t *sshTunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, fmt.Errorf("the tunnel through SSH bastion %v is closed", t.host)
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+	verbose.Debugf("Connecting to SSH bastion %v@%v", t.clientConfig.User, t.host)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial SSH bastion %v: %v", t.host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.host, t.clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH handshake with %v failed: %v", t.host, err)
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+	t.client = client
+	go func() {
+		// A dropped SSH connection is replaced on the next dial
+		client.Wait()
+		t.forget(client)
+	}()
+	return client, nil
+}
+
+// forget drops the SSH connection, if it's still the shared one
+func (t *sshTunnel) forget(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == client {
+		t.client = nil
+	}
+	client.Close()
+}
+
+func (t *sshTunnel) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verbose.Debugf("Dialing %v:%v via SSH bastion %v", network, address, t.host)
+	conn, err := client.DialContext(ctx, network, address)
+	if err != nil {
+		// The SSH connection may be broken, the next dial reconnects
+		t.forget(client)
+		return nil, fmt.Errorf("SSH bastion %v failed to dial %v: %v", t.host, address, err)
+	}
+	return conn, nil
+}
+
+// Close closes the SSH connection and the connection to the SSH agent. The
+// tunnel can't dial afterwards.
+func (t *sshTunnel) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+	}
+	if t.agentConn != nil {
+		t.agentConn.Close()
+	}
+}
@@ -0,0 +1,309 @@
+package transport
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testSSHServer is an SSH bastion forwarding "direct-tcpip" channels, like
+// "ssh -L" expects
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.Signer
+	mu       sync.Mutex
+	conns    []*ssh.ServerConn
+	logins   int
+}
+
+func newSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer, key
+}
+
+func startSSHServer(t *testing.T, authorizedKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	hostKey, _ := newSigner(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &testSSHServer{listener: listener, hostKey: hostKey}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "admin" && string(key.Marshal()) == string(authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	s.config.AddHostKey(hostKey)
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropSessions()
+	})
+	return s
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, sshConn)
+	s.logins++
+	s.mu.Unlock()
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		if newChan.ChannelType() != "direct-tcpip" {
+			newChan.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newChan.ExtraData(), &target); err != nil {
+			newChan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		backend, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelReqs, err := newChan.Accept()
+		if err != nil {
+			backend.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelReqs)
+		go func() {
+			io.Copy(channel, backend)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(backend, channel)
+			backend.Close()
+		}()
+	}
+}
+
+// dropSessions closes the SSH connections of every client
+func (s *testSSHServer) dropSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) loginCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// startHealthServer starts a gRPC server serving the health service
+func startHealthServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// writeSSHFiles writes the client key and the known_hosts trusting hostKey for
+// the bastion at address, and returns their paths
+func writeSSHFiles(t *testing.T, clientKey ed25519.PrivateKey, address string, hostKey ssh.PublicKey) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, hostKey)
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write known_hosts: %v", err)
+	}
+	return keyFile, knownHosts
+}
+
+func TestSSHJump(t *testing.T) {
+	clientSigner, clientKey := newSigner(t)
+	bastion := startSSHServer(t, clientSigner.PublicKey())
+	target := startHealthServer(t)
+	address := bastion.listener.Addr().String()
+	keyFile, knownHosts := writeSSHFiles(t, clientKey, address, bastion.hostKey.PublicKey())
+
+	backend, err := Connect(context.Background(), config.ServerConfig{
+		RealAddress: target,
+		SSHJump: &config.SSHJumpConfig{
+			Host:       address,
+			User:       "admin",
+			KeyFile:    keyFile,
+			KnownHosts: knownHosts,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	defer backend.Close()
+	status, err := backend.GetHealthStatus(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetHealthStatus() failed: %v", err)
+	}
+	if status != "SERVING" {
+		t.Errorf("GetHealthStatus() = %v, want SERVING", status)
+	}
+	if got := bastion.loginCount(); got != 1 {
+		t.Errorf("%v logins to the bastion, want 1", got)
+	}
+}
+
+func TestSSHJumpRejected(t *testing.T) {
+	clientSigner, clientKey := newSigner(t)
+	_, otherKey := newSigner(t)
+	otherHostKey, _ := newSigner(t)
+	bastion := startSSHServer(t, clientSigner.PublicKey())
+	target := startHealthServer(t)
+	address := bastion.listener.Addr().String()
+
+	tests := []struct {
+		name      string
+		clientKey ed25519.PrivateKey
+		hostKey   ssh.PublicKey
+		wantErr   string
+	}{
+		{
+			name:      "unknown host key",
+			clientKey: clientKey,
+			hostKey:   otherHostKey.PublicKey(),
+			wantErr:   "key mismatch",
+		},
+		{
+			name:      "unauthorized client key",
+			clientKey: otherKey,
+			hostKey:   bastion.hostKey.PublicKey(),
+			wantErr:   "unable to authenticate",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyFile, knownHosts := writeSSHFiles(t, test.clientKey, address, test.hostKey)
+			_, err := Connect(context.Background(), config.ServerConfig{
+				RealAddress:    target,
+				ConnectTimeout: time.Second,
+				SSHJump: &config.SSHJumpConfig{
+					Host:       address,
+					User:       "admin",
+					KeyFile:    keyFile,
+					KnownHosts: knownHosts,
+				},
+			})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Connect() = %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSSHTunnelReconnects(t *testing.T) {
+	clientSigner, clientKey := newSigner(t)
+	bastion := startSSHServer(t, clientSigner.PublicKey())
+	target := startHealthServer(t)
+	address := bastion.listener.Addr().String()
+	keyFile, knownHosts := writeSSHFiles(t, clientKey, address, bastion.hostKey.PublicKey())
+
+	tunnel, err := newSSHTunnel(&config.SSHJumpConfig{
+		Host:       address,
+		User:       "admin",
+		KeyFile:    keyFile,
+		KnownHosts: knownHosts,
+	})
+	if err != nil {
+		t.Fatalf("newSSHTunnel() failed: %v", err)
+	}
+	defer tunnel.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := tunnel.dial(ctx, "tcp", target)
+	if err != nil {
+		t.Fatalf("dial() failed: %v", err)
+	}
+	conn.Close()
+
+	// The dropped SSH connection is replaced by the next dial
+	bastion.dropSessions()
+	for {
+		conn, err = tunnel.dial(ctx, "tcp", target)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("dial() after the session dropped failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := bastion.loginCount(); got != 2 {
+		t.Errorf("%v logins to the bastion, want 2", got)
+	}
+
+	tunnel.Close()
+	if _, err := tunnel.dial(ctx, "tcp", target); err == nil {
+		t.Errorf("dial() after Close() succeeded, want error")
+	}
+}
@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/verbose"
+	"google.golang.org/grpc"
+	zpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	channelzservice "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/xds"
+)
+
+// newXdsResolver builds an xDS resolver dedicated to the given bootstrap file
+func newXdsResolver(bootstrapFile string) (resolver.Builder, error) {
+	bootstrap, err := os.ReadFile(bootstrapFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xDS bootstrap: %v", err)
+	}
+	// This is the only API of gRPC taking a bootstrap config other than from
+	// the environment variables, which are read once at startup.
+	builder, err := xds.NewXDSResolverWithConfigForTesting(bootstrap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xDS resolver: %v", err)
+	}
+	return builder, nil
+}
+
+// channelzRegistrar captures the channelz service, to query the channels of
+// this process without serving it
+type channelzRegistrar struct {
+	server zpb.ChannelzServer
+}
+
+func (r *channelzRegistrar) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	r.server = impl.(zpb.ChannelzServer)
+}
+
+// localChannelz is the channelz service of this process
+var localChannelz = func() zpb.ChannelzServer {
+	registrar := &channelzRegistrar{}
+	channelzservice.RegisterChannelzServiceToServer(registrar)
+	return registrar.server
+}()
+
+// topChannelIDs returns the IDs of the top channels of this process with the
+// target
+func topChannelIDs(ctx context.Context, target string) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	for startID := int64(0); ; {
+		resp, err := localChannelz.GetTopChannels(ctx, &zpb.GetTopChannelsRequest{StartChannelId: startID})
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range resp.Channel {
+			if channel.GetData().GetTarget() == target {
+				ids[channel.GetRef().GetChannelId()] = true
+			}
+			startID = channel.GetRef().GetChannelId() + 1
+		}
+		if resp.End {
+			return ids, nil
+		}
+	}
+}
+
+// errResolveOnly fails the connections to the backends, which are only
+// listed
+var errResolveOnly = errors.New("only resolving the backends")
+
+// resolveXdsBackends returns the addresses of the backends the load balancer
+// of the xds:/// target picks from. They are only known to the load balancing
+// policies configured by the control plane, so the target is dialed, without
+// connecting to the backends, until every backend failed to connect, and the
+// addresses of the subchannels are read from channelz.
+func resolveXdsBackends(ctx context.Context, c config.ServerConfig) ([]string, error) {
+	dialOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return nil, errResolveOnly
+		}),
+	}
+	if c.XdsBootstrap != "" {
+		xdsResolver, err := newXdsResolver(c.XdsBootstrap)
+		if err != nil {
+			return nil, err
+		}
+		dialOptions = append(dialOptions, grpc.WithResolvers(xdsResolver))
+	}
+	connectTimeout, _ := timeouts(c)
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	existing, err := topChannelIDs(ctx, c.RealAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+	}
+	conn, err := grpc.NewClient(c.RealAddress, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+	}
+	defer conn.Close()
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.TransientFailure; state = conn.GetState() {
+		if !conn.WaitForStateChange(ctx, state) {
+			return nil, fmt.Errorf("timed out resolving %v", c.RealAddress)
+		}
+	}
+	ids, err := topChannelIDs(ctx, c.RealAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+	}
+	var backends []string
+	for id := range ids {
+		if existing[id] {
+			continue
+		}
+		channel, err := localChannelz.GetChannel(ctx, &zpb.GetChannelRequest{ChannelId: id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+		}
+		for _, ref := range channel.GetChannel().GetSubchannelRef() {
+			subchannel, err := localChannelz.GetSubchannel(ctx, &zpb.GetSubchannelRequest{SubchannelId: ref.SubchannelId})
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %v: %v", c.RealAddress, err)
+			}
+			backends = append(backends, subchannel.GetSubchannel().GetData().GetTarget())
+		}
+	}
+	if len(backends) == 0 {
+		// The failure of an RPC tells why, e.g. a missing xDS resource
+		_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return nil, fmt.Errorf("%v resolved to no backend addresses: %v", c.RealAddress, err)
+	}
+	sort.Strings(backends)
+	verbose.Debugf("%v balances between %v", c.RealAddress, backends)
+	return backends, nil
+}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpcdebug/cmd/config"
+	"github.com/grpc-ecosystem/grpcdebug/internal/testing/xdsserver"
+)
+
+func TestResolveXdsBackends(t *testing.T) {
+	backends := []string{"10.0.0.1:8080", "10.0.0.2:8080"}
+	// The unhealthy backend is never picked
+	bootstrap := xdsserver.Start(t, "my-service", backends, []string{"10.0.0.3:8080"})
+
+	got, err := ResolveBackends(context.Background(), config.ServerConfig{RealAddress: "xds:///my-service", XdsBootstrap: bootstrap})
+	if err != nil {
+		t.Fatalf("ResolveBackends() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, backends) {
+		t.Errorf("ResolveBackends() = %v, want %v", got, backends)
+	}
+}
+
+func TestResolveXdsBackendsUnknownListener(t *testing.T) {
+	bootstrap := xdsserver.Start(t, "my-service", []string{"10.0.0.1:8080"}, nil)
+	_, err := ResolveBackends(context.Background(), config.ServerConfig{
+		RealAddress:    "xds:///other-service",
+		XdsBootstrap:   bootstrap,
+		ConnectTimeout: 500 * time.Millisecond,
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out resolving") {
+		t.Fatalf("ResolveBackends() = %v, want error of timing out", err)
+	}
+}
+
+func TestConnectXds(t *testing.T) {
+	target := startHealthServer(t)
+	bootstrap := xdsserver.Start(t, "my-service", []string{target}, nil)
+	backend, err := Connect(context.Background(), config.ServerConfig{RealAddress: "xds:///my-service", XdsBootstrap: bootstrap})
+	if err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+	defer backend.Close()
+	status, err := backend.GetHealthStatus(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetHealthStatus() failed: %v", err)
+	}
+	if status != "SERVING" {
+		t.Errorf("GetHealthStatus() = %v, want SERVING", status)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// version is set by the release builds, e.g.
+// -ldflags "-X github.com/grpc-ecosystem/grpcdebug/cmd.version=v1.2.3"
+var version string
+
+// buildVersion returns the version of the binary, falling back to the module
+// version recorded by "go install"
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// versionInfo is the version of grpcdebug, as printed by the structured
+// output formats
+type versionInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+func versionCommandRunWithError(cmd *cobra.Command, args []string) error {
+	info := versionInfo{
+		Version:   buildVersion(),
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%v/%v", runtime.GOOS, runtime.GOARCH),
+	}
+	var r report
+	r.newTable("").addRow(fmt.Sprintf("grpcdebug version %v (%v %v)", info.Version, info.GoVersion, info.Platform))
+	r.setData(info)
+	return printReport(&r)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of grpcdebug.",
+	Args:  cobra.NoArgs,
+	RunE:  versionCommandRunWithError,
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
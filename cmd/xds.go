@@ -1,12 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 
-	"github.com/grpc-ecosystem/grpcdebug/cmd/transport"
-
 	adminpb "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
 	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	endpointpb "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
@@ -14,28 +14,13 @@ import (
 	csdspb "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
 	"github.com/golang/protobuf/ptypes"
 	timestamppb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/grpc-ecosystem/grpcdebug/cmd/printer"
 	"github.com/spf13/cobra"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
 var xdsTypeFlag string
 
-func printProtoBufMessageAsJSON(m proto.Message) error {
-	option := protojson.MarshalOptions{
-		Multiline:      true,
-		Indent:         "  ",
-		UseProtoNames:  false,
-		UseEnumNumbers: false,
-	}
-	jsonbytes, err := option.Marshal(m)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(jsonbytes))
-	return nil
-}
-
 func priorityPerXdsConfig(x *csdspb.PerXdsConfig) int {
 	switch x.PerXdsConfig.(type) {
 	case *csdspb.PerXdsConfig_ListenerConfig:
@@ -57,15 +42,19 @@ func sortPerXdsConfigs(clientStatus *csdspb.ClientStatusResponse) {
 	})
 }
 
-func xdsConfigCommandRunWithError(cmd *cobra.Command, args []string) error {
-	clientStatus := transport.FetchClientStatus()
+func xdsConfigCommandRunWithError(ctx context.Context, t *target, args []string) error {
+	clientStatus, err := t.backend.FetchClientStatus(ctx)
+	if err != nil {
+		return err
+	}
 	if len(clientStatus.Config) != 1 {
 		return fmt.Errorf("Received unexpected number of ClientConfig %v", len(clientStatus.Config))
 	}
 	if xdsTypeFlag == "" {
 		// No filters, just print the whole thing
 		sortPerXdsConfigs(clientStatus)
-		return printProtoBufMessageAsJSON(clientStatus)
+		t.setData(clientStatus)
+		return nil
 	}
 	// Parse flags
 	wantXdsTypes := strings.Split(xdsTypeFlag, ",")
@@ -83,6 +72,7 @@ func xdsConfigCommandRunWithError(cmd *cobra.Command, args []string) error {
 		}
 	}
 	// Filter the CSDS output
+	printSubjects := printer.Documents{}
 	for _, genericXdsConfig := range clientStatus.Config[0].GenericXdsConfigs {
 		var printSubject proto.Message
 		tokens := strings.Split(genericXdsConfig.TypeUrl, ".")
@@ -105,10 +95,7 @@ func xdsConfigCommandRunWithError(cmd *cobra.Command, args []string) error {
 			}
 		}
 		if printSubject != nil {
-			err := printProtoBufMessageAsJSON(printSubject)
-			if err != nil {
-				return fmt.Errorf("Failed to print xDS config: %v", err)
-			}
+			printSubjects = append(printSubjects, printSubject)
 		}
 	}
 	if len(clientStatus.Config[0].GenericXdsConfigs) == 0 {
@@ -133,20 +120,18 @@ func xdsConfigCommandRunWithError(cmd *cobra.Command, args []string) error {
 				}
 			}
 			if printSubject != nil {
-				err := printProtoBufMessageAsJSON(printSubject)
-				if err != nil {
-					return fmt.Errorf("Failed to print xDS config: %v", err)
-				}
+				printSubjects = append(printSubjects, printSubject)
 			}
 		}
 	}
+	t.setData(printSubjects)
 	return nil
 }
 
 var xdsConfigCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Dump the operating xDS configs.",
-	RunE:  xdsConfigCommandRunWithError,
+	RunE:  forEachTarget(xdsConfigCommandRunWithError),
 	Args:  cobra.NoArgs,
 }
 
@@ -158,9 +143,23 @@ type xdsResourceStatusEntry struct {
 	LastUpdated *timestamppb.Timestamp
 }
 
-func printStatusEntry(entry *xdsResourceStatusEntry) {
-	fmt.Fprintf(
-		w, "%v\t%v\t%v\t%v\t%v\t\n",
+// MarshalJSON names the fields like the CSDS messages, e.g. "lastUpdated"
+func (entry *xdsResourceStatusEntry) MarshalJSON() ([]byte, error) {
+	var lastUpdated string
+	if entry.LastUpdated != nil {
+		lastUpdated = ptypes.TimestampString(entry.LastUpdated)
+	}
+	return json.Marshal(struct {
+		Name        string `json:"name"`
+		Status      string `json:"status"`
+		Version     string `json:"version,omitempty"`
+		Type        string `json:"type,omitempty"`
+		LastUpdated string `json:"lastUpdated,omitempty"`
+	}{entry.Name, entry.Status.String(), entry.Version, entry.Type, lastUpdated})
+}
+
+func printStatusEntry(table *section, entry *xdsResourceStatusEntry) {
+	table.addRow(
 		entry.Name,
 		entry.Status,
 		entry.Version,
@@ -169,13 +168,16 @@ func printStatusEntry(entry *xdsResourceStatusEntry) {
 	)
 }
 
-func xdsStatusCommandRunWithError(cmd *cobra.Command, args []string) error {
-	clientStatus := transport.FetchClientStatus()
+func xdsStatusCommandRunWithError(ctx context.Context, t *target, args []string) error {
+	clientStatus, err := t.backend.FetchClientStatus(ctx)
+	if err != nil {
+		return err
+	}
 	if len(clientStatus.Config) != 1 {
 		return fmt.Errorf("Received unexpected number of ClientConfig %v", len(clientStatus.Config))
 	}
 
-	fmt.Fprintln(w, "Name\tStatus\tVersion\tType\tLastUpdated")
+	entries := []*xdsResourceStatusEntry{}
 	config := clientStatus.Config[0]
 	for _, genericXdsConfig := range config.GenericXdsConfigs {
 		entry := xdsResourceStatusEntry{
@@ -185,7 +187,7 @@ func xdsStatusCommandRunWithError(cmd *cobra.Command, args []string) error {
 			Type:        genericXdsConfig.TypeUrl,
 			LastUpdated: genericXdsConfig.LastUpdated,
 		}
-		printStatusEntry(&entry)
+		entries = append(entries, &entry)
 	}
 	if len(config.GenericXdsConfigs) == 0 {
 		for _, xdsConfig := range config.XdsConfig {
@@ -201,7 +203,7 @@ func xdsStatusCommandRunWithError(cmd *cobra.Command, args []string) error {
 						entry.Type = state.Listener.TypeUrl
 						entry.LastUpdated = state.LastUpdated
 					}
-					printStatusEntry(&entry)
+					entries = append(entries, &entry)
 				}
 			case *csdspb.PerXdsConfig_RouteConfig:
 				for _, dynamicRouteConfig := range xdsConfig.GetRouteConfig().DynamicRouteConfigs {
@@ -218,7 +220,7 @@ func xdsStatusCommandRunWithError(cmd *cobra.Command, args []string) error {
 						}
 						entry.Name = routeConfig.Name
 					}
-					printStatusEntry(&entry)
+					entries = append(entries, &entry)
 				}
 			case *csdspb.PerXdsConfig_ClusterConfig:
 				for _, dynamicCluster := range xdsConfig.GetClusterConfig().DynamicActiveClusters {
@@ -235,7 +237,7 @@ func xdsStatusCommandRunWithError(cmd *cobra.Command, args []string) error {
 						}
 						entry.Name = cluster.Name
 					}
-					printStatusEntry(&entry)
+					entries = append(entries, &entry)
 				}
 			case *csdspb.PerXdsConfig_EndpointConfig:
 				for _, dynamicEndpoint := range xdsConfig.GetEndpointConfig().GetDynamicEndpointConfigs() {
@@ -252,19 +254,23 @@ func xdsStatusCommandRunWithError(cmd *cobra.Command, args []string) error {
 						}
 						entry.Name = endpoint.ClusterName
 					}
-					printStatusEntry(&entry)
+					entries = append(entries, &entry)
 				}
 			}
 		}
 	}
-	w.Flush()
+	table := t.newTable("", "Name", "Status", "Version", "Type", "LastUpdated")
+	for _, entry := range entries {
+		printStatusEntry(table, entry)
+	}
+	t.setData(entries)
 	return nil
 }
 
 var xdsStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Print the config synchronization status.",
-	RunE:  xdsStatusCommandRunWithError,
+	RunE:  forEachTarget(xdsStatusCommandRunWithError),
 }
 
 var xdsCmd = &cobra.Command{
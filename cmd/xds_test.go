@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	adminpb "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerpb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	csdspb "github.com/envoyproxy/go-control-plane/envoy/service/status/v3"
+	"github.com/grpc-ecosystem/grpcdebug/internal/testing/xdsserver"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func fakeCSDSBackend(t *testing.T) *fakeBackend {
+	t.Helper()
+	listener, err := anypb.New(&listenerpb.Listener{Name: "my-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := anypb.New(&clusterpb.Cluster{Name: "my-cluster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeBackend{
+		clientStatus: &csdspb.ClientStatusResponse{
+			Config: []*csdspb.ClientConfig{
+				{
+					GenericXdsConfigs: []*csdspb.ClientConfig_GenericXdsConfig{
+						{
+							TypeUrl:      "type.googleapis.com/envoy.config.listener.v3.Listener",
+							Name:         "my-service",
+							VersionInfo:  "1",
+							XdsConfig:    listener,
+							ClientStatus: adminpb.ClientResourceStatus_ACKED,
+						},
+						{
+							TypeUrl:      "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+							Name:         "my-cluster",
+							VersionInfo:  "2",
+							XdsConfig:    cluster,
+							ClientStatus: adminpb.ClientResourceStatus_NACKED,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestXdsStatus(t *testing.T) {
+	backends := map[string]*fakeBackend{"localhost:50051": fakeCSDSBackend(t)}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "table",
+			args: []string{"localhost:50051", "xds", "status"},
+			want: "Name         Status    Version   Type                                                    LastUpdated   \n" +
+				"my-service   ACKED     1         type.googleapis.com/envoy.config.listener.v3.Listener                 \n" +
+				"my-cluster   NACKED    2         type.googleapis.com/envoy.config.cluster.v3.Cluster                   \n",
+		},
+		{
+			name: "json",
+			args: []string{"localhost:50051", "xds", "status", "-o", "json"},
+			want: `[
+  {
+    "name": "my-service",
+    "status": "ACKED",
+    "version": "1",
+    "type": "type.googleapis.com/envoy.config.listener.v3.Listener"
+  },
+  {
+    "name": "my-cluster",
+    "status": "NACKED",
+    "version": "2",
+    "type": "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+  }
+]
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := runCommand(t, backends, test.args...)
+			if result.err != nil {
+				t.Fatalf("grpcdebug %v failed: %v\n%v", test.args, result.err, result.stderr)
+			}
+			if result.stdout != test.want {
+				t.Errorf("grpcdebug %v printed:\n%v\nwant:\n%v", test.args, result.stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestXdsConfig(t *testing.T) {
+	backends := map[string]*fakeBackend{"localhost:50051": fakeCSDSBackend(t)}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			// Without tables, the table formats print a JSON document per
+			// resource, like before --output
+			name: "table",
+			args: []string{"localhost:50051", "xds", "config", "--type=lds,cds"},
+			want: `{
+  "@type": "type.googleapis.com/envoy.config.listener.v3.Listener",
+  "name": "my-service"
+}
+{
+  "@type": "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+  "name": "my-cluster"
+}
+`,
+		},
+		{
+			name: "json",
+			args: []string{"localhost:50051", "xds", "config", "--type=CDS", "-o", "json"},
+			want: `[
+  {
+    "@type": "type.googleapis.com/envoy.config.cluster.v3.Cluster",
+    "name": "my-cluster"
+  }
+]
+`,
+		},
+		{
+			name: "yaml",
+			args: []string{"localhost:50051", "xds", "config", "--type=lds,cds", "-o", "yaml"},
+			want: `- '@type': type.googleapis.com/envoy.config.listener.v3.Listener
+  name: my-service
+- '@type': type.googleapis.com/envoy.config.cluster.v3.Cluster
+  name: my-cluster
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := runCommand(t, backends, test.args...)
+			if result.err != nil {
+				t.Fatalf("grpcdebug %v failed: %v\n%v", test.args, result.err, result.stderr)
+			}
+			if result.stdout != test.want {
+				t.Errorf("grpcdebug %v printed:\n%v\nwant:\n%v", test.args, result.stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestXdsWithoutCSDS(t *testing.T) {
+	backends := map[string]*fakeBackend{"localhost:50051": {}}
+	result := runCommand(t, backends, "localhost:50051", "xds", "status")
+	if result.err == nil {
+		t.Fatalf("grpcdebug xds status succeeded without CSDS, want error")
+	}
+}
+
+func TestXdsEachBackend(t *testing.T) {
+	bootstrap := xdsserver.Start(t, "my-service", []string{"10.0.0.1:8080", "10.0.0.2:8080"}, []string{"10.0.0.3:8080"})
+	backends := map[string]*fakeBackend{
+		"10.0.0.1:8080": {health: map[string]string{"": "SERVING"}},
+		"10.0.0.2:8080": {health: map[string]string{"": "NOT_SERVING"}},
+	}
+	result := runCommand(t, backends, "xds:///my-service", "health", "--each_backend", "--xds_bootstrap", bootstrap, "-o", "csv")
+	if result.err != nil {
+		t.Fatalf("grpcdebug health --each_backend failed: %v\n%v", result.err, result.stderr)
+	}
+	want := "10.0.0.1:8080,<Overall>,SERVING\n" +
+		"10.0.0.2:8080,<Overall>,NOT_SERVING\n"
+	if result.stdout != want {
+		t.Errorf("grpcdebug health --each_backend printed:\n%v\nwant:\n%v", result.stdout, want)
+	}
+	var connected []string
+	for _, c := range result.connected {
+		connected = append(connected, c.RealAddress)
+	}
+	// The backends are connected to concurrently
+	sort.Strings(connected)
+	if want := []string{"10.0.0.1:8080", "10.0.0.2:8080"}; !reflect.DeepEqual(connected, want) {
+		t.Errorf("grpcdebug health --each_backend connected to %v, want %v", connected, want)
+	}
+}
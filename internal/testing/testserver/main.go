@@ -4,19 +4,24 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"math/rand"
 	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/channelz/service"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // Serves the clients compressing admin RPCs
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/testdata"
@@ -34,6 +39,11 @@ var (
 	servingPortFlag     = flag.Int("serving", 10001, "the serving port")
 	adminPortFlag       = flag.Int("admin", 50051, "the admin port")
 	secureAdminPortFlag = flag.Int("secure_admin", 50052, "the secure admin port")
+	mtlsAdminPortFlag   = flag.Int("mtls_admin", 50053, "the admin port requiring client certificates")
+	udsAdminFlag        = flag.String("uds_admin", filepath.Join(os.TempDir(), "grpcdebug_admin.sock"), "the path of the admin Unix domain socket, disabled if empty")
+	proxyPortFlag       = flag.Int("proxy", 50054, "the port of the HTTP CONNECT proxy")
+	proxyAuthFlag       = flag.String("proxy_auth", "", "the user:password required by the HTTP CONNECT proxy, disabled if empty")
+	abstractAdminFlag   = flag.String("abstract_admin", "", "the name of the admin abstract Unix domain socket (Linux only), disabled if empty")
 	healthFlag          = flag.Bool("health", true, "the health checking status")
 	qpsFlag             = flag.Int("qps", 10, "The size of the generated load against itself")
 	abortPercentageFlag = flag.Int("abort_percentage", 10, "The percentage of failed RPCs")
@@ -47,7 +57,7 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	configDump, err := ioutil.ReadAll(file)
+	configDump, err := io.ReadAll(file)
 	if err != nil {
 		panic(err)
 	}
@@ -93,6 +103,52 @@ func setupAdminServer(s *grpc.Server) {
 	healthpb.RegisterHealthServer(s, healthcheck)
 }
 
+func serveAdminOnUnixSocket(path string) {
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		panic(err)
+	}
+	s := grpc.NewServer()
+	setupAdminServer(s)
+	go s.Serve(lis)
+}
+
+// Implements a minimal HTTP CONNECT proxy
+type connectProxy struct {
+	auth string
+}
+
+func (p *connectProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodConnect {
+		http.Error(rw, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if p.auth != "" && req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(p.auth)) {
+		http.Error(rw, "invalid proxy credentials", http.StatusProxyAuthRequired)
+		return
+	}
+	upstream, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	downstream, buffered, err := rw.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	fmt.Fprint(downstream, "HTTP/1.1 200 Connection established\r\n\r\n")
+	log.Printf("Proxying %v to %v", downstream.RemoteAddr(), req.Host)
+	go func() {
+		io.Copy(upstream, buffered)
+		upstream.Close()
+	}()
+	go func() {
+		io.Copy(downstream, upstream)
+		downstream.Close()
+	}()
+}
+
 func main() {
 	// Parse the flags
 	flag.Parse()
@@ -135,6 +191,51 @@ func main() {
 	go secureAdminServer.Serve(secureListener)
 	defer secureAdminServer.Stop()
 	fmt.Printf("Serving Secure Admin Services on :%d\n", *secureAdminPortFlag)
+	// Creates the admin server requiring mutual TLS
+	mtlsListener, err := net.Listen("tcp", fmt.Sprintf(":%d", *mtlsAdminPortFlag))
+	if err != nil {
+		panic(err)
+	}
+	defer mtlsListener.Close()
+	mtlsCert, err := tls.LoadX509KeyPair(testdata.Path("x509/server1_cert.pem"), testdata.Path("x509/server1_key.pem"))
+	if err != nil {
+		log.Fatalf("failed to load key pair: %s", err)
+	}
+	clientCA, err := os.ReadFile(testdata.Path("x509/client_ca_cert.pem"))
+	if err != nil {
+		log.Fatalf("failed to load client CA: %s", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCA)
+	mtlsAdminServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{mtlsCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})))
+	setupAdminServer(mtlsAdminServer)
+	go mtlsAdminServer.Serve(mtlsListener)
+	defer mtlsAdminServer.Stop()
+	fmt.Printf("Serving mTLS Admin Services on :%d\n", *mtlsAdminPortFlag)
+	// Creates the admin servers listening on Unix domain sockets
+	if *udsAdminFlag != "" {
+		// Remove the socket left behind by a previous run
+		os.Remove(*udsAdminFlag)
+		serveAdminOnUnixSocket(*udsAdminFlag)
+		defer os.Remove(*udsAdminFlag)
+		fmt.Printf("Serving Insecure Admin Services on unix:%s\n", *udsAdminFlag)
+	}
+	if *abstractAdminFlag != "" {
+		serveAdminOnUnixSocket("@" + *abstractAdminFlag)
+		fmt.Printf("Serving Insecure Admin Services on unix-abstract:%s\n", *abstractAdminFlag)
+	}
+	// Creates the HTTP CONNECT proxy
+	proxyListener, err := net.Listen("tcp", fmt.Sprintf(":%d", *proxyPortFlag))
+	if err != nil {
+		panic(err)
+	}
+	defer proxyListener.Close()
+	go http.Serve(proxyListener, &connectProxy{auth: *proxyAuthFlag})
+	fmt.Printf("Serving HTTP CONNECT Proxy on :%d\n", *proxyPortFlag)
 	// Creates a client to hydrate the primary server
 	creds, err := credentials.NewClientTLSFromFile(testdata.Path("ca.pem"), "*.test.youtube.com")
 	if err != nil {
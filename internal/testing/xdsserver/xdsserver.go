@@ -0,0 +1,151 @@
+// Package xdsserver runs a go-control-plane management server for tests
+package xdsserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	clusterpb "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corepb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointpb "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listenerpb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	routerpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	hcmpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	discoverypb "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const nodeID = "grpcdebug-test"
+
+const bootstrapTemplate = `{
+  "xds_servers": [{"server_uri": %q, "channel_creds": [{"type": "insecure"}]}],
+  "node": {"id": %q}
+}`
+
+// lbEndpoint returns the endpoint at address, of the given health
+func lbEndpoint(tb testing.TB, address string, health corepb.HealthStatus) *endpointpb.LbEndpoint {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		tb.Fatalf("invalid backend address %q: %v", address, err)
+	}
+	portValue, err := strconv.Atoi(port)
+	if err != nil {
+		tb.Fatalf("invalid backend address %q: %v", address, err)
+	}
+	return &endpointpb.LbEndpoint{
+		HealthStatus: health,
+		HostIdentifier: &endpointpb.LbEndpoint_Endpoint{Endpoint: &endpointpb.Endpoint{
+			Address: &corepb.Address{Address: &corepb.Address_SocketAddress{SocketAddress: &corepb.SocketAddress{
+				Address:       host,
+				PortSpecifier: &corepb.SocketAddress_PortValue{PortValue: uint32(portValue)},
+			}}},
+		}},
+	}
+}
+
+// resources returns the listener of service, routing through RDS to an EDS
+// cluster of the backends. The unhealthy backends are listed too, and another
+// cluster is routed to by a catch-all virtual host.
+func resources(tb testing.TB, service string, backends, unhealthy []string) map[resource.Type][]types.Resource {
+	router, err := anypb.New(&routerpb.Router{})
+	if err != nil {
+		tb.Fatalf("failed to marshal router: %v", err)
+	}
+	hcm, err := anypb.New(&hcmpb.HttpConnectionManager{
+		// gRPC requires the router as the last filter
+		HttpFilters: []*hcmpb.HttpFilter{{Name: "router", ConfigType: &hcmpb.HttpFilter_TypedConfig{TypedConfig: router}}},
+		RouteSpecifier: &hcmpb.HttpConnectionManager_Rds{Rds: &hcmpb.Rds{
+			ConfigSource:    &corepb.ConfigSource{ConfigSourceSpecifier: &corepb.ConfigSource_Ads{Ads: &corepb.AggregatedConfigSource{}}},
+			RouteConfigName: service + "-route",
+		}},
+	})
+	if err != nil {
+		tb.Fatalf("failed to marshal HTTP connection manager: %v", err)
+	}
+	routeTo := func(cluster string) []*routepb.Route {
+		return []*routepb.Route{{
+			Match:  &routepb.RouteMatch{PathSpecifier: &routepb.RouteMatch_Prefix{Prefix: "/"}},
+			Action: &routepb.Route_Route{Route: &routepb.RouteAction{ClusterSpecifier: &routepb.RouteAction_Cluster{Cluster: cluster}}},
+		}}
+	}
+	var lbEndpoints []*endpointpb.LbEndpoint
+	for _, backend := range backends {
+		lbEndpoints = append(lbEndpoints, lbEndpoint(tb, backend, corepb.HealthStatus_HEALTHY))
+	}
+	for _, backend := range unhealthy {
+		lbEndpoints = append(lbEndpoints, lbEndpoint(tb, backend, corepb.HealthStatus_UNHEALTHY))
+	}
+	return map[resource.Type][]types.Resource{
+		resource.ListenerType: {&listenerpb.Listener{
+			Name:        service,
+			ApiListener: &listenerpb.ApiListener{ApiListener: hcm},
+		}},
+		resource.RouteType: {&routepb.RouteConfiguration{
+			Name: service + "-route",
+			VirtualHosts: []*routepb.VirtualHost{
+				{Name: "default", Domains: []string{"*"}, Routes: routeTo("default-cluster")},
+				{Name: service, Domains: []string{service}, Routes: routeTo(service + "-cluster")},
+			},
+		}},
+		resource.ClusterType: {&clusterpb.Cluster{
+			Name:                 service + "-cluster",
+			ClusterDiscoveryType: &clusterpb.Cluster_Type{Type: clusterpb.Cluster_EDS},
+			EdsClusterConfig: &clusterpb.Cluster_EdsClusterConfig{
+				EdsConfig:   &corepb.ConfigSource{ConfigSourceSpecifier: &corepb.ConfigSource_Ads{Ads: &corepb.AggregatedConfigSource{}}},
+				ServiceName: service + "-endpoints",
+			},
+		}},
+		resource.EndpointType: {&endpointpb.ClusterLoadAssignment{
+			ClusterName: service + "-endpoints",
+			Endpoints: []*endpointpb.LocalityLbEndpoints{{
+				// gRPC requires an ID and a weight for each locality
+				Locality:            &corepb.Locality{Region: "test"},
+				LoadBalancingWeight: wrapperspb.UInt32(1),
+				LbEndpoints:         lbEndpoints,
+			}},
+		}},
+	}
+}
+
+// Start starts a management server serving xds:///service, which resolves to
+// the backends, and returns the path of a bootstrap file pointing to it
+func Start(tb testing.TB, service string, backends, unhealthy []string) string {
+	tb.Helper()
+	snapshot, err := cache.NewSnapshot("1", resources(tb, service, backends, unhealthy))
+	if err != nil {
+		tb.Fatalf("failed to create snapshot: %v", err)
+	}
+	snapshotCache := cache.NewSnapshotCache(true, cache.IDHash{}, nil)
+	if err := snapshotCache.SetSnapshot(context.Background(), nodeID, snapshot); err != nil {
+		tb.Fatalf("failed to set snapshot: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	discoverypb.RegisterAggregatedDiscoveryServiceServer(grpcServer, server.NewServer(ctx, snapshotCache, nil))
+	go grpcServer.Serve(listener)
+	tb.Cleanup(grpcServer.Stop)
+
+	bootstrap := filepath.Join(tb.TempDir(), "bootstrap.json")
+	contents := fmt.Sprintf(bootstrapTemplate, listener.Addr().String(), nodeID)
+	if err := os.WriteFile(bootstrap, []byte(contents), 0600); err != nil {
+		tb.Fatalf("failed to write bootstrap: %v", err)
+	}
+	return bootstrap
+}